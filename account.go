@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+// UserSessions is every way a user is currently or was recently signed in.
+type UserSessions struct {
+	RefreshTokens []database.GetRefreshTokensUserRow `json:"refresh_tokens"`
+	APITokens     []APITokenResponse                 `json:"api_tokens"`
+	Passkeys      []database.Passkey                 `json:"passkeys"`
+	OAuthGrants   []database.GetOAuthGrantsUserRow   `json:"oauth_authorizations"`
+}
+
+func (cfg *apiConfig) userSessions(ctx context.Context, userID uuid.UUID) (UserSessions, error) {
+	sessions := UserSessions{}
+	var err error
+	sessions.RefreshTokens, err = cfg.dbq.GetRefreshTokensUser(ctx, userID)
+	if err != nil {
+		return UserSessions{}, err
+	}
+	tokens, err := cfg.dbq.GetAPITokensUser(ctx, userID)
+	if err != nil {
+		return UserSessions{}, err
+	}
+	for _, t := range tokens {
+		sessions.APITokens = append(sessions.APITokens, apiTokenResponse(t))
+	}
+	sessions.Passkeys, err = cfg.dbq.GetPasskeysUser(ctx, userID)
+	if err != nil {
+		return UserSessions{}, err
+	}
+	for i := range sessions.Passkeys {
+		sessions.Passkeys[i].PublicKey = nil
+	}
+	sessions.OAuthGrants, err = cfg.dbq.GetOAuthGrantsUser(ctx, userID)
+	if err != nil {
+		return UserSessions{}, err
+	}
+	return sessions, nil
+}
+
+// isSuspended is checked on every login, refresh and authenticated request,
+// so a suspension takes effect even against unexpired access tokens.
+func (cfg *apiConfig) isSuspended(ctx context.Context, userID uuid.UUID) (bool, error) {
+	_, err := cfg.dbq.GetUserSuspension(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (cfg *apiConfig) deleteAccount(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	type deleteInput struct {
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := deleteInput{}
+	err := decoder.Decode(&input)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	user, err := cfg.dbq.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	// The password check shares the login throttle, so a stolen token can't
+	// be used to guess the password here instead.
+	ip := clientIP(r)
+	if wait := cfg.loginLockedFor(r.Context(), user.Email, ip); wait > 0 {
+		writeLocked(w, wait)
+		return
+	}
+	err = cfg.passwords.CheckPasswordHash(input.Password, user.HashedPassword)
+	if err != nil {
+		cfg.recordLoginFailure(r.Context(), user.Email, uuid.NullUUID{UUID: user.ID, Valid: true}, ip, "bad password on account deletion")
+		w.WriteHeader(401)
+		return
+	}
+
+	deletion, err := cfg.dbq.ScheduleAccountDeletion(r.Context(), database.ScheduleAccountDeletionParams{UserID: user.ID, DeleteAfter: time.Now().Add(cfg.deletionGrace)})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	err = cfg.dbq.RevokeUserRefreshTokens(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	err = cfg.dbq.RevokeUserAPITokens(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(user.ID), Action: "account.delete_requested", TargetType: "user", TargetID: user.ID.String(), IP: clientIP(r), Details: map[string]time.Time{"delete_after": deletion.DeleteAfter}})
+
+	body, err := json.Marshal(deletion)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(202)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) schedulePurgeAccounts() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := cfg.dbq.PurgeDeletedAccounts(context.Background())
+		if err != nil {
+			log.Printf("Purge deleted accounts: %s", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Purged %d deleted accounts", n)
+			cfg.sweepExports(context.Background())
+			cfg.audit(context.Background(), auditEvent{Action: "account.purge", Details: map[string]int64{"accounts": n}})
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+const ADMIN_SEARCH_LIMIT = 50
+
+type AdminUserResponse struct {
+	ID          uuid.UUID                `json:"id"`
+	CreatedAt   time.Time                `json:"created_at"`
+	UpdatedAt   time.Time                `json:"updated_at"`
+	Email       string                   `json:"email"`
+	Role        string                   `json:"role"`
+	IsChirpyRed bool                     `json:"is_chirpy_red"`
+	Suspension  *database.UserSuspension `json:"suspension,omitempty"`
+	Visibility  *database.UserVisibility `json:"visibility,omitempty"`
+	Sessions    *UserSessions            `json:"sessions,omitempty"`
+}
+
+func adminUserResponse(user database.User) AdminUserResponse {
+	return AdminUserResponse{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, Role: user.Role, IsChirpyRed: user.IsChirpyRed}
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// adminTarget loads the user named by the {userID} path value, writing the
+// error response itself when there isn't one.
+func (cfg *apiConfig) adminTarget(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	id, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		w.WriteHeader(400)
+		return database.User{}, false
+	}
+	user, err := cfg.dbq.GetUserByID(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(404)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func (cfg *apiConfig) adminSearchUsers(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	// Escape LIKE wildcards so the search is a plain substring match.
+	q := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(r.URL.Query().Get("email"))
+
+	users, err := cfg.dbq.SearchUsers(r.Context(), database.SearchUsersParams{Email: "%" + q + "%", Limit: ADMIN_SEARCH_LIMIT})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []AdminUserResponse{}
+	for _, u := range users {
+		res = append(res, adminUserResponse(u))
+	}
+	writeAdminJSON(w, 200, res)
+}
+
+func (cfg *apiConfig) adminGetUser(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	res := adminUserResponse(user)
+	suspension, err := cfg.dbq.GetUserSuspension(r.Context(), user.ID)
+	if err == nil {
+		res.Suspension = &suspension
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(500)
+		return
+	}
+	visibility, err := cfg.dbq.GetUserVisibility(r.Context(), user.ID)
+	if err == nil {
+		res.Visibility = &visibility
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(500)
+		return
+	}
+	sessions, err := cfg.userSessions(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	res.Sessions = &sessions
+
+	writeAdminJSON(w, 200, res)
+}
+
+func (cfg *apiConfig) adminSuspendUser(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	if user.ID == principal.UserID {
+		w.WriteHeader(400)
+		return
+	}
+
+	type suspendInput struct {
+		Reason string `json:"reason"`
+	}
+
+	input := suspendInput{}
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+	}
+
+	suspension, err := cfg.dbq.SuspendUser(r.Context(), database.SuspendUserParams{
+		UserID:      user.ID,
+		SuspendedBy: uuid.NullUUID{UUID: principal.UserID, Valid: true},
+		Reason:      input.Reason,
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	err = cfg.dbq.RevokeUserRefreshTokens(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "user.suspend", TargetType: "user", TargetID: user.ID.String(), IP: clientIP(r), Details: map[string]string{"reason": input.Reason}})
+
+	writeAdminJSON(w, 200, suspension)
+}
+
+func (cfg *apiConfig) adminUnsuspendUser(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := cfg.dbq.UnsuspendUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if n == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "user.unsuspend", TargetType: "user", TargetID: user.ID.String(), IP: clientIP(r)})
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) adminLogoutUser(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.dbq.RevokeUserRefreshTokens(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "user.logout", TargetType: "user", TargetID: user.ID.String(), IP: clientIP(r)})
+	w.WriteHeader(204)
+}
+
+// adminSetUserRole changes a user's role. It shows up in their access
+// tokens from the next refresh on.
+func (cfg *apiConfig) adminSetUserRole(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	// Admins can't demote themselves, so there's always one left.
+	if user.ID == principal.UserID {
+		w.WriteHeader(400)
+		return
+	}
+
+	type roleInput struct {
+		Role string `json:"role"`
+	}
+
+	input := roleInput{}
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || !auth.ValidRole(input.Role) {
+		w.WriteHeader(400)
+		return
+	}
+
+	updated, err := cfg.dbq.SetUserRole(r.Context(), database.SetUserRoleParams{ID: user.ID, Role: input.Role})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "user.role", TargetType: "user", TargetID: user.ID.String(), IP: clientIP(r), Details: map[string]string{"role": updated.Role, "previous_role": user.Role}})
+
+	writeAdminJSON(w, 200, adminUserResponse(updated))
+}
+
+func (cfg *apiConfig) adminResetChirpyRed(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.setSubscription(r.Context(), user.ID, "admin.reset", "canceled", sql.NullTime{}, time.Now())
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	user, err = cfg.dbq.GetUserByID(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "user.chirpy_red_reset", TargetType: "user", TargetID: user.ID.String(), IP: clientIP(r)})
+	writeAdminJSON(w, 200, adminUserResponse(user))
+}
+
+func (cfg *apiConfig) adminDeleteUser(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+	if user.ID == principal.UserID {
+		w.WriteHeader(400)
+		return
+	}
+
+	_, err := cfg.dbq.DeleteUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.sweepExports(r.Context())
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "user.delete", TargetType: "user", TargetID: user.ID.String(), IP: clientIP(r), Details: map[string]string{"email": user.Email}})
+	w.WriteHeader(204)
+}
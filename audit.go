@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/audit"
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+const AUDIT_PAGE_SIZE = 100
+
+// Appends race other instances for the head of the chain; the loser
+// retries against the new head.
+const AUDIT_APPEND_RETRIES = 5
+
+type auditEvent struct {
+	Actor      uuid.NullUUID
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	Details    any
+}
+
+func auditActor(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
+
+func auditRecord(e database.AuditLog) audit.Record {
+	return audit.Record{
+		Seq:        e.Seq,
+		CreatedAt:  e.CreatedAt,
+		ActorID:    e.ActorID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.Ip,
+		Details:    e.Details,
+		PrevHash:   e.PrevHash,
+		Hash:       e.Hash,
+	}
+}
+
+// audit appends an event to the audit log. Failures are logged rather than
+// returned so that a problem with the log never blocks the action itself.
+func (cfg *apiConfig) audit(ctx context.Context, e auditEvent) {
+	details := ""
+	if e.Details != nil {
+		b, err := json.Marshal(e.Details)
+		if err != nil {
+			log.Printf("Audit %s: %s", e.Action, err)
+			return
+		}
+		details = string(b)
+	}
+
+	cfg.auditMu.Lock()
+	defer cfg.auditMu.Unlock()
+
+	var err error
+	for i := 0; i < AUDIT_APPEND_RETRIES; i++ {
+		var last database.AuditLog
+		last, err = cfg.dbq.GetLastAuditEntry(ctx)
+		prev := ""
+		if err == nil {
+			prev = last.Hash
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+
+		rec := audit.Record{
+			CreatedAt:  audit.Timestamp(time.Now()),
+			ActorID:    e.Actor,
+			Action:     e.Action,
+			TargetType: e.TargetType,
+			TargetID:   e.TargetID,
+			IP:         e.IP,
+			Details:    details,
+			PrevHash:   prev,
+		}
+		_, err = cfg.dbq.AppendAuditEntry(ctx, database.AppendAuditEntryParams{
+			CreatedAt:  rec.CreatedAt,
+			ActorID:    rec.ActorID,
+			Action:     rec.Action,
+			TargetType: rec.TargetType,
+			TargetID:   rec.TargetID,
+			Ip:         rec.IP,
+			Details:    rec.Details,
+			PrevHash:   rec.PrevHash,
+			Hash:       rec.ComputeHash(),
+		})
+		if err == nil {
+			return
+		}
+	}
+	log.Printf("Audit %s: %v", e.Action, err)
+}
+
+func (cfg *apiConfig) getAudit(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	q := r.URL.Query()
+	params := database.GetAuditEntriesParams{BeforeSeq: math.MaxInt64, MaxEntries: AUDIT_PAGE_SIZE}
+
+	if s := q.Get("actor_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		params.ActorID = auditActor(id)
+	}
+	if s := q.Get("target_id"); s != "" {
+		params.TargetID = sql.NullString{String: s, Valid: true}
+	}
+	if s := q.Get("action"); s != "" {
+		params.Action = sql.NullString{String: s, Valid: true}
+	}
+	if s := q.Get("before"); s != "" {
+		before, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		params.BeforeSeq = before
+	}
+
+	entries, err := cfg.dbq.GetAuditEntries(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if entries == nil {
+		entries = []database.AuditLog{}
+	}
+	writeAdminJSON(w, 200, entries)
+}
+
+// verifyAudit walks the whole chain from the start, a page at a time.
+func (cfg *apiConfig) verifyAudit(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	type verifyResponse struct {
+		Valid   bool   `json:"valid"`
+		Entries int64  `json:"entries"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	res := verifyResponse{Valid: true}
+	prev := ""
+	params := database.GetAuditEntriesAfterParams{Seq: 0, Limit: 1000}
+	for {
+		page, err := cfg.dbq.GetAuditEntriesAfter(r.Context(), params)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		records := []audit.Record{}
+		for _, e := range page {
+			records = append(records, auditRecord(e))
+		}
+		err = audit.Verify(prev, records)
+		if err != nil {
+			res.Valid = false
+			res.Error = err.Error()
+			break
+		}
+		res.Entries += int64(len(page))
+		if len(page) < int(params.Limit) {
+			break
+		}
+		prev = page[len(page)-1].Hash
+		params.Seq = page[len(page)-1].Seq
+	}
+
+	writeAdminJSON(w, 200, res)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+const usage = `usage:
+  chirpy                                        run the server
+  chirpy breach-filter <corpus> <out> [fprate]  build a breached password filter
+  chirpy create-admin <email>                   promote a user to admin, creating
+                                                it with a password read from stdin
+                                                if it doesn't exist`
+
+func runCommand(args []string) error {
+	switch args[0] {
+	case "breach-filter":
+		return buildBreachFilter(args[1:])
+	case "create-admin":
+		return createAdmin(args[1:])
+	}
+	return errors.New(usage)
+}
+
+func buildBreachFilter(args []string) error {
+	if len(args) < 2 {
+		return errors.New(usage)
+	}
+	fpRate := 0.001
+	if len(args) > 2 {
+		var err error
+		fpRate, err = strconv.ParseFloat(args[2], 64)
+		if err != nil || fpRate <= 0 || fpRate >= 1 {
+			return errors.New("fprate must be between 0 and 1")
+		}
+	}
+
+	filter, err := auth.BuildBreachFilter(args[0], fpRate)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	n, err := filter.WriteTo(out)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d byte breach filter to %s\n", n, args[1])
+	return out.Close()
+}
+
+func createAdmin(args []string) error {
+	if len(args) != 1 {
+		return errors.New(usage)
+	}
+	email := args[0]
+
+	db, err := sql.Open("postgres", os.Getenv("DB_URL"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	dbq := database.New(db)
+	ctx := context.Background()
+
+	user, err := dbq.GetUser(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = createAdminUser(ctx, dbq, email)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = dbq.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: auth.RoleAdmin})
+	if err != nil {
+		return err
+	}
+	cfg := apiConfig{dbq: dbq}
+	cfg.audit(ctx, auditEvent{Action: "user.role", TargetType: "user", TargetID: user.ID.String(), Details: map[string]string{"role": auth.RoleAdmin, "via": "create-admin"}})
+	fmt.Printf("%s is now an admin\n", email)
+	return nil
+}
+
+func createAdminUser(ctx context.Context, dbq *database.Queries, email string) (database.User, error) {
+	passwords, err := loadPasswordHasher()
+	if err != nil {
+		return database.User{}, err
+	}
+
+	policy, err := loadPasswordPolicy(passwords)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for new user %s: ", email)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return database.User{}, err
+	}
+	password := strings.TrimRight(line, "\r\n")
+
+	violations := policy.Validate(password, email)
+	if len(violations) > 0 {
+		return database.User{}, errors.New(violations[0].Message)
+	}
+
+	hashed, err := passwords.HashedPassword(password)
+	if err != nil {
+		return database.User{}, err
+	}
+	return dbq.CreateUser(ctx, database.CreateUserParams{Email: email, HashedPassword: hashed})
+}
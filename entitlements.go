@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+	"github.com/haneyeric/chirpy/internal/entitlements"
+)
+
+func loadPlans() (entitlements.Plans, error) {
+	path := os.Getenv("PLANS")
+	if path == "" {
+		return entitlements.DefaultPlans, nil
+	}
+	return entitlements.LoadPlans(path)
+}
+
+func userPlan(user database.User) entitlements.Plan {
+	if user.IsChirpyRed {
+		return entitlements.PlanRed
+	}
+	return entitlements.PlanFree
+}
+
+// userEntitlements is the one place handlers find out what a user's plan
+// lets them do.
+func (cfg *apiConfig) userEntitlements(user database.User) entitlements.Entitlements {
+	return cfg.plans.For(userPlan(user))
+}
+
+func (cfg *apiConfig) getEntitlements(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, err := cfg.dbq.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	type entitlementsResponse struct {
+		Plan         entitlements.Plan         `json:"plan"`
+		Entitlements entitlements.Entitlements `json:"entitlements"`
+	}
+
+	body, err := json.Marshal(entitlementsResponse{Plan: userPlan(user), Entitlements: cfg.userEntitlements(user)})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
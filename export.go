@@ -0,0 +1,323 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+// Chirps are read and written a page at a time so an export never holds a
+// user's whole history in memory.
+const EXPORT_PAGE_SIZE = 500
+
+// A user can't start an export while another is pending, unless that one
+// has been pending for longer than EXPORT_TIMEOUT.
+const EXPORT_TIMEOUT = time.Hour
+
+func (cfg *apiConfig) exportPath(id uuid.UUID) string {
+	return filepath.Join(cfg.exportDir, id.String()+".zip")
+}
+
+func (cfg *apiConfig) startExport(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	err := cfg.dbq.FailStaleDataExports(r.Context(), database.FailStaleDataExportsParams{UserID: principal.UserID, StaleBefore: time.Now().Add(-EXPORT_TIMEOUT)})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	export, err := cfg.dbq.CreateDataExport(r.Context(), principal.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(409)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	go cfg.runExport(export)
+
+	body, err := json.Marshal(export)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/users/me/export/"+export.ID.String())
+	w.WriteHeader(202)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) getExport(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	export, err := cfg.dbq.GetDataExport(r.Context(), database.GetDataExportParams{ID: id, UserID: principal.UserID})
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+	if export.ExpiresAt.Before(time.Now()) {
+		w.WriteHeader(410)
+		return
+	}
+
+	if export.Status != "complete" {
+		body, err := json.Marshal(export)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(body)
+		return
+	}
+
+	file, err := os.Open(cfg.exportPath(export.ID))
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	defer file.Close()
+
+	name := fmt.Sprintf("chirpy-export-%s.zip", export.CreatedAt.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, export.UpdatedAt, file)
+}
+
+func (cfg *apiConfig) runExport(export database.DataExport) {
+	ctx := context.Background()
+	path := cfg.exportPath(export.ID)
+
+	size, err := cfg.writeExport(ctx, export.UserID, path)
+	if err != nil {
+		log.Printf("Export %s failed: %s", export.ID, err)
+		os.Remove(path + ".tmp")
+		err = cfg.dbq.FailDataExport(ctx, export.ID)
+		if err != nil {
+			log.Printf("Mark export %s failed: %s", export.ID, err)
+		}
+		return
+	}
+
+	err = cfg.dbq.CompleteDataExport(ctx, database.CompleteDataExportParams{ID: export.ID, Size: size})
+	if err != nil {
+		log.Printf("Mark export %s complete: %s", export.ID, err)
+	}
+}
+
+// writeExport builds the archive in a temporary file next to path and only
+// moves it into place once it is complete.
+func (cfg *apiConfig) writeExport(ctx context.Context, userID uuid.UUID, path string) (int64, error) {
+	file, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+
+	user, err := cfg.dbq.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	err = writeExportJSON(archive, "profile.json", LoginResponse{Id: user.ID, Created_at: user.CreatedAt, Updated_at: user.UpdatedAt, Email: user.Email, IsChirpyRed: user.IsChirpyRed})
+	if err != nil {
+		return 0, err
+	}
+
+	err = cfg.writeExportChirps(ctx, archive, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	// The whole subscription history goes in, however long it is.
+	subscription, err := cfg.userSubscription(ctx, user, math.MaxInt32)
+	if err != nil {
+		return 0, err
+	}
+	err = writeExportJSON(archive, "subscription.json", subscription)
+	if err != nil {
+		return 0, err
+	}
+
+	sessions, err := cfg.userSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	err = writeExportJSON(archive, "sessions.json", sessions)
+	if err != nil {
+		return 0, err
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	err = file.Close()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(path+".tmp", path)
+}
+
+func writeExportJSON(archive *zip.Writer, name string, v any) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// ExportChirp is a chirp as its author gets it in an export: deleted ones
+// included, along with the bodies edits replaced.
+type ExportChirp struct {
+	ID        uuid.UUID                `json:"id"`
+	CreatedAt time.Time                `json:"created_at"`
+	UpdatedAt time.Time                `json:"updated_at"`
+	Body      string                   `json:"body"`
+	EditedAt  *time.Time               `json:"edited_at"`
+	DeletedAt *time.Time               `json:"deleted_at,omitempty"`
+	Revisions []database.ChirpRevision `json:"revisions,omitempty"`
+}
+
+func (cfg *apiConfig) writeExportChirps(ctx context.Context, archive *zip.Writer, userID uuid.UUID) error {
+	w, err := archive.Create("chirps.json")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte("["))
+	if err != nil {
+		return err
+	}
+
+	params := database.GetChirpsUserPageParams{UserID: userID, Limit: EXPORT_PAGE_SIZE}
+	sep := "\n"
+	for {
+		page, err := cfg.dbq.GetChirpsUserPage(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		ids := []uuid.UUID{}
+		for _, chirp := range page {
+			ids = append(ids, chirp.ID)
+		}
+		revisions, err := cfg.dbq.GetChirpRevisionsChirps(ctx, ids)
+		if err != nil {
+			return err
+		}
+		byChirp := map[uuid.UUID][]database.ChirpRevision{}
+		for _, rev := range revisions {
+			byChirp[rev.ChirpID] = append(byChirp[rev.ChirpID], rev)
+		}
+
+		for _, chirp := range page {
+			body, err := json.Marshal(ExportChirp{ID: chirp.ID, CreatedAt: chirp.CreatedAt, UpdatedAt: chirp.UpdatedAt, Body: chirp.Body, EditedAt: chirp.EditedAt, DeletedAt: chirp.DeletedAt, Revisions: byChirp[chirp.ID]})
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(append([]byte(sep), body...))
+			if err != nil {
+				return err
+			}
+			sep = ",\n"
+		}
+		if len(page) < EXPORT_PAGE_SIZE {
+			break
+		}
+		last := page[len(page)-1]
+		params.CreatedAt, params.ID = last.CreatedAt, last.ID
+	}
+
+	_, err = w.Write([]byte("\n]\n"))
+	return err
+}
+
+func (cfg *apiConfig) schedulePurgeExports() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		ids, err := cfg.dbq.DeleteExpiredDataExports(context.Background())
+		if err != nil {
+			log.Printf("Purge expired exports: %s", err)
+			continue
+		}
+		for _, id := range ids {
+			err = os.Remove(cfg.exportPath(id))
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("Remove export %s: %s", id, err)
+			}
+		}
+		cfg.sweepExports(context.Background())
+	}
+}
+
+// sweepExports removes archives whose export no longer exists. Exports go
+// with their user's account, which leaves the files behind.
+func (cfg *apiConfig) sweepExports(ctx context.Context) {
+	// The directory is read before the IDs so an export started in between
+	// is never taken for an orphan.
+	entries, err := os.ReadDir(cfg.exportDir)
+	if err != nil {
+		log.Printf("Sweep exports: %s", err)
+		return
+	}
+	ids, err := cfg.dbq.GetDataExportIDs(ctx)
+	if err != nil {
+		log.Printf("Sweep exports: %s", err)
+		return
+	}
+	known := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		known[id] = true
+	}
+
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".tmp")
+		id, err := uuid.Parse(strings.TrimSuffix(name, ".zip"))
+		if err != nil || !strings.HasSuffix(name, ".zip") || known[id] {
+			continue
+		}
+		err = os.Remove(filepath.Join(cfg.exportDir, entry.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Remove export %s: %s", entry.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+	"github.com/haneyeric/chirpy/internal/filter"
+)
+
+// Rules are reloaded on every change made through this instance and
+// periodically to pick up changes made through others.
+const FILTER_RELOAD = time.Minute
+
+func (cfg *apiConfig) reloadFilter(ctx context.Context) error {
+	rows, err := cfg.dbq.GetFilterRules(ctx)
+	if err != nil {
+		return err
+	}
+	rules := []filter.Rule{}
+	for _, row := range rows {
+		rules = append(rules, filter.Rule{Pattern: row.Pattern, Action: filter.Action(row.Action)})
+	}
+	cfg.wordFilter.Store(filter.New(rules))
+	return nil
+}
+
+func (cfg *apiConfig) scheduleFilterReload() {
+	ticker := time.NewTicker(FILTER_RELOAD)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := cfg.reloadFilter(context.Background())
+		if err != nil {
+			log.Printf("Reload filter rules: %s", err)
+		}
+	}
+}
+
+// flagChirp puts a chirp the filter flagged into the moderation queue.
+func (cfg *apiConfig) flagChirp(ctx context.Context, chirp database.Chirp, res filter.Result) {
+	_, err := cfg.dbq.CreateReport(ctx, database.CreateReportParams{ChirpID: chirp.ID, Category: "filter", Details: strings.Join(res.Matches, " ")})
+	if err != nil {
+		log.Printf("Flag chirp %s: %s", chirp.ID, err)
+	}
+}
+
+func (cfg *apiConfig) getFilterRules(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	rules, err := cfg.dbq.GetFilterRules(r.Context())
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if rules == nil {
+		rules = []database.FilterRule{}
+	}
+	writeAdminJSON(w, 200, rules)
+}
+
+func (cfg *apiConfig) putFilterRule(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	type ruleInput struct {
+		Pattern string `json:"pattern"`
+		Action  string `json:"action"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := ruleInput{}
+	err := decoder.Decode(&input)
+	if err != nil || filter.Normalize(input.Pattern) == "" || !filter.ValidAction(filter.Action(input.Action)) {
+		w.WriteHeader(400)
+		return
+	}
+
+	rule, err := cfg.dbq.UpsertFilterRule(r.Context(), database.UpsertFilterRuleParams{Pattern: strings.TrimSpace(input.Pattern), Action: input.Action})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "filter.rule_set", TargetType: "filter_rule", TargetID: rule.ID.String(), IP: clientIP(r), Details: input})
+	err = cfg.reloadFilter(r.Context())
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	writeAdminJSON(w, 200, rule)
+}
+
+func (cfg *apiConfig) deleteFilterRule(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	id, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	n, err := cfg.dbq.DeleteFilterRule(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if n == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "filter.rule_delete", TargetType: "filter_rule", TargetID: id.String(), IP: clientIP(r)})
+	err = cfg.reloadFilter(r.Context())
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
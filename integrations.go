@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+	"github.com/haneyeric/chirpy/internal/entitlements"
+	"github.com/haneyeric/chirpy/internal/webhooks"
+)
+
+const WEBHOOK_ENDPOINTS_LIMIT = 10
+const WEBHOOK_DELIVERIES_LIMIT = 50
+
+// Due deliveries are picked up every WEBHOOK_POLL, up to WEBHOOK_BATCH at a
+// time, and are leased for WEBHOOK_LEASE while they're being sent.
+const WEBHOOK_POLL = 5 * time.Second
+const WEBHOOK_BATCH = 50
+const WEBHOOK_LEASE = 2 * time.Minute
+const WEBHOOK_TIMEOUT = 10 * time.Second
+
+// A delivery is given up on after WEBHOOK_MAX_ATTEMPTS, and an endpoint is
+// disabled after WEBHOOK_DISABLE_AFTER failed attempts in a row across all
+// its deliveries.
+const WEBHOOK_MAX_ATTEMPTS = 10
+const WEBHOOK_DISABLE_AFTER = 25
+
+// Finished deliveries and their attempt logs are kept this long.
+const WEBHOOK_RETENTION = 30 * 24 * time.Hour
+
+type WebhookEndpointResponse struct {
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	Secret              string     `json:"secret,omitempty"`
+}
+
+func webhookEndpointResponse(e database.WebhookEndpoint) WebhookEndpointResponse {
+	return WebhookEndpointResponse{ID: e.ID, CreatedAt: e.CreatedAt, URL: e.Url, Events: strings.Fields(e.EventTypes), ConsecutiveFailures: e.ConsecutiveFailures, DisabledAt: nullTimePtr(e.DisabledAt)}
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID                         `json:"id"`
+	CreatedAt      time.Time                         `json:"created_at"`
+	EventID        uuid.UUID                         `json:"event_id"`
+	EventType      string                            `json:"event_type"`
+	Payload        json.RawMessage                   `json:"payload"`
+	Status         string                            `json:"status"`
+	Attempts       int32                             `json:"attempts"`
+	NextAttemptAt  *time.Time                        `json:"next_attempt_at"`
+	LastStatusCode int32                             `json:"last_status_code"`
+	LastError      string                            `json:"last_error"`
+	DeliveredAt    *time.Time                        `json:"delivered_at"`
+	AttemptLog     []database.WebhookDeliveryAttempt `json:"attempt_log,omitempty"`
+}
+
+func webhookDeliveryResponse(d database.WebhookDelivery) WebhookDeliveryResponse {
+	res := WebhookDeliveryResponse{ID: d.ID, CreatedAt: d.CreatedAt, EventID: d.EventID, EventType: d.EventType, Payload: json.RawMessage(d.Payload), Status: d.Status, Attempts: d.Attempts, LastStatusCode: d.LastStatusCode, LastError: d.LastError, DeliveredAt: nullTimePtr(d.DeliveredAt)}
+	if d.Status == "pending" {
+		res.NextAttemptAt = &d.NextAttemptAt
+	}
+	return res
+}
+
+// WebhookPayload is the body of every delivery. ID is shared by the
+// deliveries of one event to different endpoints.
+type WebhookPayload struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type webhookChirpDeleted struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	Reason  string    `json:"reason"`
+}
+
+type webhookUserUpgraded struct {
+	UserID           uuid.UUID         `json:"user_id"`
+	Plan             entitlements.Plan `json:"plan"`
+	CurrentPeriodEnd *time.Time        `json:"current_period_end"`
+}
+
+// emitWebhook queues an event about userID for their endpoints subscribed
+// to it. Failing to queue it doesn't fail whatever caused it.
+func (cfg *apiConfig) emitWebhook(ctx context.Context, userID uuid.UUID, event string, data any) {
+	id := uuid.New()
+	payload, err := json.Marshal(WebhookPayload{ID: id, Type: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		log.Printf("Webhook %s for %s: %s", event, userID, err)
+		return
+	}
+	_, err = cfg.dbq.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{EventID: id, EventType: event, Payload: string(payload), UserID: userID})
+	if err != nil {
+		log.Printf("Webhook %s for %s: %s", event, userID, err)
+	}
+}
+
+// validWebhookURL accepts absolute https URLs, and plain http in dev. URLs
+// naming an internal address outright are refused here; hostnames are
+// checked each time a delivery resolves them.
+func (cfg *apiConfig) validWebhookURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || u.User != nil {
+		return false
+	}
+	if cfg.platform == "dev" {
+		return u.Scheme == "https" || u.Scheme == "http"
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !webhooks.Allowed(ip) {
+		return false
+	}
+	return u.Scheme == "https"
+}
+
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	type endpointInput struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := endpointInput{}
+	err := decoder.Decode(&input)
+	if err != nil || !cfg.validWebhookURL(input.URL) || len(input.Events) == 0 {
+		w.WriteHeader(400)
+		return
+	}
+	for _, e := range input.Events {
+		if !slices.Contains(webhooks.EventTypes, e) {
+			w.WriteHeader(400)
+			return
+		}
+	}
+	slices.Sort(input.Events)
+	input.Events = slices.Compact(input.Events)
+
+	existing, err := cfg.dbq.GetWebhookEndpointsUser(r.Context(), principal.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if len(existing) >= WEBHOOK_ENDPOINTS_LIMIT {
+		w.WriteHeader(409)
+		return
+	}
+
+	secret, err := webhooks.MakeSecret()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	endpoint, err := cfg.dbq.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID:     principal.UserID,
+		Url:        input.URL,
+		Secret:     secret,
+		EventTypes: strings.Join(input.Events, " "),
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "webhook_endpoint.create", TargetType: "webhook_endpoint", TargetID: endpoint.ID.String(), IP: clientIP(r), Details: map[string]string{"url": endpoint.Url, "events": endpoint.EventTypes}})
+
+	// The signing secret is only ever shown here.
+	res := webhookEndpointResponse(endpoint)
+	res.Secret = secret
+	body, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) getWebhookEndpoints(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	endpoints, err := cfg.dbq.GetWebhookEndpointsUser(r.Context(), principal.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []WebhookEndpointResponse{}
+	for _, e := range endpoints {
+		res = append(res, webhookEndpointResponse(e))
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	eid, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	n, err := cfg.dbq.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{ID: eid, UserID: principal.UserID})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if n == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "webhook_endpoint.delete", TargetType: "webhook_endpoint", TargetID: eid.String(), IP: clientIP(r)})
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) enableWebhookEndpoint(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	eid, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	n, err := cfg.dbq.EnableWebhookEndpoint(r.Context(), database.EnableWebhookEndpointParams{ID: eid, UserID: principal.UserID})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if n == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "webhook_endpoint.enable", TargetType: "webhook_endpoint", TargetID: eid.String(), IP: clientIP(r)})
+	w.WriteHeader(204)
+}
+
+// webhookEndpointTarget loads the caller's endpoint named by the
+// {endpointID} path value, writing the error response itself when there
+// isn't one.
+func (cfg *apiConfig) webhookEndpointTarget(w http.ResponseWriter, r *http.Request, principal auth.Principal) (database.WebhookEndpoint, bool) {
+	eid, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := cfg.dbq.GetWebhookEndpoint(r.Context(), eid)
+	if err != nil || endpoint.UserID != principal.UserID {
+		w.WriteHeader(404)
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+func (cfg *apiConfig) getWebhookDeliveries(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	endpoint, ok := cfg.webhookEndpointTarget(w, r, principal)
+	if !ok {
+		return
+	}
+
+	deliveries, err := cfg.dbq.GetWebhookDeliveriesEndpoint(r.Context(), database.GetWebhookDeliveriesEndpointParams{EndpointID: endpoint.ID, Limit: WEBHOOK_DELIVERIES_LIMIT})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []WebhookDeliveryResponse{}
+	for _, d := range deliveries {
+		res = append(res, webhookDeliveryResponse(d))
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) getWebhookDelivery(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	endpoint, ok := cfg.webhookEndpointTarget(w, r, principal)
+	if !ok {
+		return
+	}
+	did, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	delivery, err := cfg.dbq.GetWebhookDelivery(r.Context(), database.GetWebhookDeliveryParams{ID: did, EndpointID: endpoint.ID})
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+	attempts, err := cfg.dbq.GetWebhookDeliveryAttempts(r.Context(), delivery.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := webhookDeliveryResponse(delivery)
+	res.AttemptLog = attempts
+	body, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) scheduleWebhookDeliveries() {
+	worker := &webhooks.Worker{
+		Store:        webhookStore{dbq: cfg.dbq},
+		Client:       cfg.webhookClient,
+		Lease:        WEBHOOK_LEASE,
+		Batch:        WEBHOOK_BATCH,
+		MaxAttempts:  WEBHOOK_MAX_ATTEMPTS,
+		DisableAfter: WEBHOOK_DISABLE_AFTER,
+		OnDisable:    cfg.webhookEndpointDisabled,
+	}
+
+	ticker := time.NewTicker(WEBHOOK_POLL)
+	defer ticker.Stop()
+	for range ticker.C {
+		_, err := worker.DeliverDue(context.Background())
+		if err != nil {
+			log.Printf("Deliver webhooks: %s", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) webhookEndpointDisabled(ctx context.Context, endpoint webhooks.Endpoint, failures int) {
+	log.Printf("Disabled webhook endpoint %s after %d failures", endpoint.ID, failures)
+	cfg.audit(ctx, auditEvent{Action: "webhook_endpoint.disable", TargetType: "webhook_endpoint", TargetID: endpoint.ID.String(), Details: map[string]int{"consecutive_failures": failures}})
+}
+
+func (cfg *apiConfig) schedulePurgeWebhookDeliveries() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := cfg.dbq.PurgeWebhookDeliveries(context.Background(), time.Now().Add(-WEBHOOK_RETENTION))
+		if err != nil {
+			log.Printf("Purge webhook deliveries: %s", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Purged %d webhook deliveries", n)
+		}
+	}
+}
+
+// webhookStore keeps the webhook delivery queue in the database.
+type webhookStore struct {
+	dbq *database.Queries
+}
+
+func (s webhookStore) ClaimDue(ctx context.Context, leaseUntil time.Time, max int) ([]webhooks.Job, error) {
+	due, err := s.dbq.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{LeaseUntil: leaseUntil, MaxDeliveries: int32(max)})
+	if err != nil {
+		return nil, err
+	}
+	jobs := []webhooks.Job{}
+	for _, d := range due {
+		jobs = append(jobs, webhooks.Job{ID: d.ID, EndpointID: d.EndpointID, Event: d.EventType, Payload: []byte(d.Payload), Attempts: int(d.Attempts)})
+	}
+	return jobs, nil
+}
+
+func (s webhookStore) Endpoint(ctx context.Context, id uuid.UUID) (webhooks.Endpoint, error) {
+	e, err := s.dbq.GetWebhookEndpoint(ctx, id)
+	if err != nil {
+		return webhooks.Endpoint{}, err
+	}
+	return webhooks.Endpoint{ID: e.ID, URL: e.Url, Secret: e.Secret, Disabled: e.DisabledAt.Valid}, nil
+}
+
+func (s webhookStore) RecordAttempt(ctx context.Context, id uuid.UUID, res webhooks.Result) error {
+	return s.dbq.CreateWebhookDeliveryAttempt(ctx, database.CreateWebhookDeliveryAttemptParams{
+		DeliveryID: id,
+		StatusCode: int32(res.StatusCode),
+		Error:      res.Message(),
+		DurationMs: int32(res.Duration.Milliseconds()),
+	})
+}
+
+func (s webhookStore) Finish(ctx context.Context, id uuid.UUID, o webhooks.Outcome) error {
+	params := database.UpdateWebhookDeliveryParams{
+		ID:             id,
+		Status:         o.Status,
+		NextAttemptAt:  o.NextAttempt,
+		LastStatusCode: int32(o.Result.StatusCode),
+		LastError:      o.Result.Message(),
+	}
+	if o.Status == webhooks.StatusDelivered {
+		params.DeliveredAt = sql.NullTime{Time: o.NextAttempt, Valid: true}
+	}
+	return s.dbq.UpdateWebhookDelivery(ctx, params)
+}
+
+func (s webhookStore) EndpointSucceeded(ctx context.Context, id uuid.UUID) error {
+	return s.dbq.ResetWebhookEndpointFailures(ctx, id)
+}
+
+func (s webhookStore) EndpointFailed(ctx context.Context, id uuid.UUID, disableAfter int) (int, bool, error) {
+	e, err := s.dbq.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{DisableAfter: int32(disableAfter), ID: id})
+	if err != nil {
+		return 0, false, err
+	}
+	// The update stamps both columns with the same NOW(), so they only
+	// match when this failure is the one that disabled the endpoint.
+	return int(e.ConsecutiveFailures), e.DisabledAt.Valid && e.DisabledAt.Time.Equal(e.UpdatedAt), nil
+}
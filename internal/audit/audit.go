@@ -0,0 +1,69 @@
+// Package audit hash-chains audit log entries so that editing, deleting or
+// reordering any of them is detectable.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Record struct {
+	Seq        int64
+	CreatedAt  time.Time
+	ActorID    uuid.NullUUID
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	Details    string
+	PrevHash   string
+	Hash       string
+}
+
+// Timestamp is the precision the database stores, so a record hashes the
+// same before it is written and after it is read back.
+func Timestamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// ComputeHash hashes every field of the record except Seq and Hash together
+// with the previous record's hash.
+func (r Record) ComputeHash() string {
+	actor := ""
+	if r.ActorID.Valid {
+		actor = r.ActorID.UUID.String()
+	}
+	fields, _ := json.Marshal([]string{
+		r.PrevHash,
+		Timestamp(r.CreatedAt).Format(time.RFC3339Nano),
+		actor,
+		r.Action,
+		r.TargetType,
+		r.TargetID,
+		r.IP,
+		r.Details,
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks that records, in sequence order, each link to the one
+// before and hash to their stored value. prev is the hash of the record
+// preceding the first one, or "" at the start of the log.
+func Verify(prev string, records []Record) error {
+	for _, r := range records {
+		if r.PrevHash != prev {
+			return fmt.Errorf("entry %d does not follow the previous entry", r.Seq)
+		}
+		if r.ComputeHash() != r.Hash {
+			return fmt.Errorf("entry %d has been altered", r.Seq)
+		}
+		prev = r.Hash
+	}
+	return nil
+}
@@ -10,53 +10,52 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashedPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), 8)
-	if err != nil {
-		return "", err
-	}
-	return string(hashed), nil
+func MakeJWT(userID uuid.UUID, role string, scopes []string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return makeJWT(Claims{Scope: strings.Join(scopes, " "), Role: role}, userID, keys, expiresIn)
 }
 
-func CheckPasswordHash(password, hash string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err
+// MakeClientJWT issues a token to a third-party client. Client tokens never
+// carry the user's role, so they can't reach staff-only routes.
+func MakeClientJWT(userID uuid.UUID, clientID string, scopes []string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return makeJWT(Claims{Scope: strings.Join(scopes, " "), ClientID: clientID}, userID, keys, expiresIn)
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Issuer: "chirpy",
+func makeJWT(claims Claims, userID uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{Issuer: "chirpy",
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String()})
-	ss, err := token.SignedString([]byte(tokenSecret))
+		Subject:   userID.String()}
+	ss, err := keys.sign(claims)
 	if err != nil {
 		return "", err
 	}
 	return ss, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
+func ValidateJWT(tokenString string, keys *KeySet) (Principal, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.keyfunc,
+		jwt.WithIssuer("chirpy"), jwt.WithValidMethods([]string{"ES256", "EdDSA"}))
 
 	if err != nil {
-		return uuid.UUID{}, err
+		return Principal{}, err
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*Claims)
 	if !ok {
-		return uuid.UUID{}, errors.New("bad claims type")
+		return Principal{}, errors.New("bad claims type")
 	}
 	id, err := uuid.Parse(claims.Subject)
 
 	if err != nil {
-		return uuid.UUID{}, err
+		return Principal{}, err
+	}
+	role := claims.Role
+	if claims.ClientID != "" || !ValidRole(role) {
+		role = RoleUser
 	}
-	return id, nil
+	return Principal{UserID: id, Scopes: strings.Fields(claims.Scope), ClientID: claims.ClientID, Role: role}, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
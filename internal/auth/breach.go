@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+var breachMagic = []byte("CHPYBF01")
+
+// BreachFilter is a bloom filter over the SHA-1 digests of breached
+// passwords, so the corpus can be checked locally without shipping or
+// querying the passwords themselves. False positives are possible; false
+// negatives are not.
+type BreachFilter struct {
+	k    uint32
+	m    uint64
+	bits []byte
+}
+
+func newBreachFilter(n uint64, fpRate float64) *BreachFilter {
+	if n == 0 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 8 {
+		m = 8
+	}
+	k := uint32(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BreachFilter{k: k, m: m, bits: make([]byte, (m+7)/8)}
+}
+
+func (f *BreachFilter) indexes(digest []byte) []uint64 {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	idx := make([]uint64, f.k)
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) % f.m
+	}
+	return idx
+}
+
+func (f *BreachFilter) add(digest []byte) {
+	for _, i := range f.indexes(digest) {
+		f.bits[i/8] |= 1 << (i % 8)
+	}
+}
+
+func (f *BreachFilter) Contains(password string) bool {
+	digest := sha1.Sum([]byte(password))
+	for _, i := range f.indexes(digest[:]) {
+		if f.bits[i/8]&(1<<(i%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *BreachFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, len(breachMagic)+12)
+	copy(header, breachMagic)
+	binary.BigEndian.PutUint32(header[8:12], f.k)
+	binary.BigEndian.PutUint64(header[12:20], f.m)
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(f.bits)
+	return int64(n + m), err
+}
+
+func LoadBreachFilter(path string) (*BreachFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 20 || !bytes.Equal(data[:8], breachMagic) {
+		return nil, errors.New("not a breach filter file")
+	}
+	f := &BreachFilter{k: binary.BigEndian.Uint32(data[8:12]), m: binary.BigEndian.Uint64(data[12:20]), bits: data[20:]}
+	if f.k == 0 || f.m == 0 || uint64(len(f.bits)) != (f.m+7)/8 {
+		return nil, errors.New("corrupt breach filter file")
+	}
+	return f, nil
+}
+
+// BuildBreachFilter reads a corpus of hex SHA-1 password hashes, one per
+// line and optionally followed by ":count" as in the Pwned Passwords
+// downloads, and builds a filter with the given false positive rate.
+func BuildBreachFilter(path string, fpRate float64) (*BreachFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	n := uint64(0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newBreachFilter(n, fpRate)
+	scanner = bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		digest, err := hex.DecodeString(line)
+		if err != nil || len(digest) != sha1.Size {
+			continue
+		}
+		f.add(digest)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// cborDecode decodes the subset of CBOR used by WebAuthn attestation objects
+// and COSE keys. It returns the decoded value and the number of bytes read so
+// callers can locate data that follows an embedded item.
+func cborDecode(b []byte) (interface{}, int, error) {
+	return cborDecodeDepth(b, 0)
+}
+
+func cborDecodeDepth(b []byte, depth int) (interface{}, int, error) {
+	if depth > 16 {
+		return nil, 0, errors.New("cbor: nesting too deep")
+	}
+	if len(b) == 0 {
+		return nil, 0, errors.New("cbor: unexpected end of data")
+	}
+	major := b[0] >> 5
+	info := b[0] & 0x1f
+	n := 1
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		if len(b) < 2 {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		arg = uint64(b[1])
+		n = 2
+	case info == 25:
+		if len(b) < 3 {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		arg = uint64(binary.BigEndian.Uint16(b[1:3]))
+		n = 3
+	case info == 26:
+		if len(b) < 5 {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		arg = uint64(binary.BigEndian.Uint32(b[1:5]))
+		n = 5
+	case info == 27:
+		if len(b) < 9 {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		arg = binary.BigEndian.Uint64(b[1:9])
+		n = 9
+	default:
+		return nil, 0, errors.New("cbor: indefinite lengths not supported")
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), n, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3:
+		if arg > uint64(len(b)-n) {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		end := n + int(arg)
+		if major == 3 {
+			return string(b[n:end]), end, nil
+		}
+		out := make([]byte, arg)
+		copy(out, b[n:end])
+		return out, end, nil
+	case 4:
+		if arg > uint64(len(b)) {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		arr := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, m, err := cborDecodeDepth(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			arr = append(arr, v)
+			n += m
+		}
+		return arr, n, nil
+	case 5:
+		if arg > uint64(len(b)) {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, kn, err := cborDecodeDepth(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += kn
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, 0, errors.New("cbor: unsupported map key")
+			}
+			v, vn, err := cborDecodeDepth(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += vn
+			m[k] = v
+		}
+		return m, n, nil
+	case 7:
+		switch info {
+		case 20:
+			return false, n, nil
+		case 21:
+			return true, n, nil
+		case 22, 23:
+			return nil, n, nil
+		}
+		return nil, 0, errors.New("cbor: unsupported simple value")
+	}
+	return nil, 0, errors.New("cbor: unsupported major type")
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt ignores everything past 72 bytes, so longer passwords are rejected
+// rather than silently truncated.
+const BCRYPT_MAX_BYTES = 72
+
+// Hasher is one password hashing algorithm. Hashes are stored in PHC string
+// format (or the modular crypt format for bcrypt) so the algorithm and its
+// parameters can be read back from the stored value.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) error
+	// Identifies reports whether encoded was produced by this algorithm.
+	Identifies(encoded string) bool
+	// Current reports whether encoded uses this hasher's parameters.
+	Current(encoded string) bool
+	// MaxBytes is the longest password the algorithm handles in full.
+	MaxBytes() int
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	if len(password) > BCRYPT_MAX_BYTES {
+		return "", errors.New("password too long")
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h BcryptHasher) Verify(password, encoded string) error {
+	return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+}
+
+func (h BcryptHasher) Identifies(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) Current(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err == nil && cost == h.Cost
+}
+
+func (h BcryptHasher) MaxBytes() int {
+	return BCRYPT_MAX_BYTES
+}
+
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(password, encoded string) error {
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+	got := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return errors.New("password does not match")
+	}
+	return nil
+}
+
+func (h Argon2idHasher) Identifies(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h Argon2idHasher) Current(encoded string) bool {
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false
+	}
+	return p.Time == h.Time && p.Memory == h.Memory && p.Threads == h.Threads &&
+		uint32(len(key)) == h.KeyLen && uint32(len(salt)) == h.SaltLen
+}
+
+func (h Argon2idHasher) MaxBytes() int {
+	return 1024
+}
+
+func decodeArgon2id(encoded string) (Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2idHasher{}, nil, nil, errors.New("not an argon2id hash")
+	}
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2idHasher{}, nil, nil, errors.New("unsupported argon2 version")
+	}
+	p := Argon2idHasher{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads)
+	if err != nil || p.Time == 0 || p.Threads == 0 {
+		return Argon2idHasher{}, nil, nil, errors.New("bad argon2 parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2idHasher{}, nil, nil, errors.New("bad argon2 hash")
+	}
+	return p, salt, key, nil
+}
+
+// Passwords hashes new passwords with the active hasher and still verifies
+// hashes made by any of the others, so stored hashes can be upgraded as
+// users log in.
+type Passwords struct {
+	active Hasher
+	all    []Hasher
+	// dummy is a hash from the slowest hasher, and floor how long verifying
+	// it took. Every check is padded out to floor so neither unknown emails
+	// nor accounts still on a cheaper legacy hash stand out by timing.
+	dummy string
+	floor time.Duration
+}
+
+const dummyPassword = "chirpy-dummy-password"
+
+func NewPasswords(active Hasher, legacy ...Hasher) (*Passwords, error) {
+	p := &Passwords{active: active, all: append([]Hasher{active}, legacy...)}
+	for _, h := range p.all {
+		dummy, err := h.Hash(dummyPassword)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		h.Verify(dummyPassword, dummy)
+		if d := time.Since(start); d > p.floor {
+			p.dummy = dummy
+			p.floor = d
+		}
+	}
+	return p, nil
+}
+
+func (p *Passwords) HashedPassword(password string) (string, error) {
+	return p.active.Hash(password)
+}
+
+func (p *Passwords) CheckPasswordHash(password, hash string) error {
+	defer p.pad(time.Now())
+	for _, h := range p.all {
+		if h.Identifies(hash) {
+			return h.Verify(password, hash)
+		}
+	}
+	return errors.New("unknown password hash format")
+}
+
+// CheckDummyPassword spends the same time as CheckPasswordHash so a login
+// for an unknown email can't be told apart from a wrong password.
+func (p *Passwords) CheckDummyPassword(password string) {
+	p.CheckPasswordHash(password, p.dummy)
+}
+
+// pad sleeps until a check begun at start has taken at least floor. Hashes
+// stored with costlier parameters than the configured ones still take
+// longer.
+func (p *Passwords) pad(start time.Time) {
+	time.Sleep(p.floor - time.Since(start))
+}
+
+func (p *Passwords) NeedsRehash(hash string) bool {
+	return !p.active.Identifies(hash) || !p.active.Current(hash)
+}
+
+func (p *Passwords) MaxBytes() int {
+	return p.active.MaxBytes()
+}
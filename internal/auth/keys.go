@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type signingKey struct {
+	kid     string
+	alg     string
+	private crypto.Signer
+	public  crypto.PublicKey
+	created time.Time
+}
+
+// KeySet holds the keys used to sign and verify access tokens. Private keys
+// and public-only keys are loaded from PEM files in dir; the file name
+// without its extension is the key's kid. The newest private key signs.
+type KeySet struct {
+	mu     sync.RWMutex
+	dir    string
+	keys   []*signingKey
+	active *signingKey
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func LoadKeySet(dir string) (*KeySet, error) {
+	ks := &KeySet{dir: dir}
+	err := ks.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func (ks *KeySet) Reload() error {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return err
+	}
+
+	keys := []*signingKey{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".pem") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(filepath.Join(ks.dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		key, err := parseKeyPEM(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		key.kid = strings.TrimSuffix(e.Name(), ".pem")
+		key.created = info.ModTime()
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].created.Before(keys[j].created)
+	})
+
+	var active *signingKey
+	for _, k := range keys {
+		if k.private != nil {
+			active = k
+		}
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = keys
+	ks.active = active
+	return nil
+}
+
+// Rotate writes a new private key to the key directory and makes it the
+// signing key. Older keys stay loaded for verification until pruned.
+func (ks *KeySet) Rotate(alg string) error {
+	var priv crypto.Signer
+	var err error
+	switch alg {
+	case "ES256":
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return errors.New("unsupported signing algorithm")
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return err
+	}
+	kid := time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	err = os.WriteFile(filepath.Join(ks.dir, kid+".pem"), data, 0600)
+	if err != nil {
+		return err
+	}
+	return ks.Reload()
+}
+
+// Prune deletes private keys that were replaced more than retain ago, which
+// should be at least the lifetime of the tokens they signed.
+func (ks *KeySet) Prune(retain time.Duration) error {
+	ks.mu.RLock()
+	keys := ks.keys
+	ks.mu.RUnlock()
+
+	removed := false
+	for i, k := range keys {
+		if k.private == nil || i == len(keys)-1 {
+			continue
+		}
+		retired := keys[i+1].created
+		if time.Since(retired) < retain {
+			continue
+		}
+		err := os.Remove(filepath.Join(ks.dir, k.kid+".pem"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		removed = true
+	}
+	if removed {
+		return ks.Reload()
+	}
+	return nil
+}
+
+func (ks *KeySet) ActiveAge() time.Duration {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if ks.active == nil {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Since(ks.active.created)
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	k := ks.active
+	ks.mu.RUnlock()
+	if k == nil {
+		return "", errors.New("no signing key")
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.alg), claims)
+	token.Header["kid"] = k.kid
+	return token.SignedString(k.private)
+}
+
+func (ks *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, k := range ks.keys {
+		if k.kid == kid {
+			if token.Method.Alg() != k.alg {
+				return nil, errors.New("algorithm does not match key")
+			}
+			return k.public, nil
+		}
+	}
+	return nil, errors.New("unknown kid")
+}
+
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		jwk := JWK{Kid: k.kid, Alg: k.alg, Use: "sig"}
+		switch pub := k.public.(type) {
+		case *ecdsa.PublicKey:
+			x := make([]byte, 32)
+			y := make([]byte, 32)
+			pub.X.FillBytes(x)
+			pub.Y.FillBytes(y)
+			jwk.Kty = "EC"
+			jwk.Crv = "P-256"
+			jwk.X = base64.RawURLEncoding.EncodeToString(x)
+			jwk.Y = base64.RawURLEncoding.EncodeToString(y)
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func parseKeyPEM(data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, errors.New("unsupported pem block " + block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("unsupported curve")
+		}
+		return &signingKey{alg: "ES256", private: k, public: &k.PublicKey}, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("unsupported curve")
+		}
+		return &signingKey{alg: "ES256", public: k}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{alg: "EdDSA", private: k, public: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &signingKey{alg: "EdDSA", public: k}, nil
+	}
+	return nil, errors.New("unsupported key type")
+}
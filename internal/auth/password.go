@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type PasswordPolicy struct {
+	MinLength  int
+	MaxBytes   int
+	MinEntropy float64
+	Breached   *BreachFilter
+}
+
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Validate returns every rule the password breaks, or nil if it is
+// acceptable for the account with the given email.
+func (p PasswordPolicy) Validate(password, email string) []PasswordViolation {
+	violations := []PasswordViolation{}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{Code: "too_short", Message: "Password is too short"})
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, PasswordViolation{Code: "too_long", Message: fmt.Sprintf("Password is longer than %d bytes", p.MaxBytes)})
+	}
+	if len(password) > 0 && PasswordEntropy(password) < p.MinEntropy {
+		violations = append(violations, PasswordViolation{Code: "too_weak", Message: "Password is too easy to guess"})
+	}
+	if containsEmail(password, email) {
+		violations = append(violations, PasswordViolation{Code: "contains_email", Message: "Password must not contain your email address"})
+	}
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, PasswordViolation{Code: "breached", Message: "Password has appeared in a data breach"})
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return violations
+}
+
+// PasswordEntropy estimates the strength of a password in bits from the size
+// of the character classes it draws on. Repeated characters only count once
+// beyond the first two uses so "aaaaaaaaaaaa" isn't scored like random text.
+func PasswordEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	counts := map[rune]int{}
+	length := 0
+	for _, c := range password {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		case c < unicode.MaxASCII && unicode.IsPrint(c):
+			symbol = true
+		default:
+			other = true
+		}
+		counts[c]++
+		if counts[c] <= 2 {
+			length++
+		}
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(length) * math.Log2(float64(pool))
+}
+
+func containsEmail(password, email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return false
+	}
+	password = strings.ToLower(password)
+	if strings.Contains(password, email) {
+		return true
+	}
+	local, _, _ := strings.Cut(email, "@")
+	return len(local) >= 3 && strings.Contains(password, local)
+}
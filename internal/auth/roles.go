@@ -0,0 +1,29 @@
+package auth
+
+import "slices"
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles is ordered from least to most privileged; each role can do
+// everything the roles before it can.
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+func ValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// HasRole reports whether the principal's role is at least role.
+func (p Principal) HasRole(role string) bool {
+	want := slices.Index(Roles, role)
+	return want >= 0 && slices.Index(Roles, p.Role) >= want
+}
+
+// Outranks reports whether the principal's role is above role, as it must
+// be to act against a user who has it.
+func (p Principal) Outranks(role string) bool {
+	return slices.Index(Roles, p.Role) > slices.Index(Roles, role)
+}
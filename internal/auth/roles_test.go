@@ -0,0 +1,22 @@
+package auth
+
+import "testing"
+
+func TestOutranks(t *testing.T) {
+	for _, tt := range []struct {
+		role, target string
+		want         bool
+	}{
+		{RoleModerator, RoleUser, true},
+		{RoleModerator, RoleModerator, false},
+		{RoleModerator, RoleAdmin, false},
+		{RoleAdmin, RoleModerator, true},
+		{RoleAdmin, RoleAdmin, false},
+		{RoleUser, RoleUser, false},
+	} {
+		p := Principal{Role: tt.role}
+		if got := p.Outranks(tt.target); got != tt.want {
+			t.Errorf("%s.Outranks(%s) = %v, want %v", tt.role, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	ScopeChirpsWrite   = "chirps:write"
+	ScopeProfileWrite  = "profile:write"
+	ScopeWebhooksWrite = "webhooks:write"
+	ScopeAdmin         = "admin"
+)
+
+var KnownScopes = []string{ScopeChirpsWrite, ScopeProfileWrite, ScopeWebhooksWrite, ScopeAdmin}
+
+// DefaultScopes are granted to tokens issued by an interactive login.
+var DefaultScopes = []string{ScopeChirpsWrite, ScopeProfileWrite, ScopeWebhooksWrite}
+
+// LoginScopes are the scopes an interactive login grants a user with role.
+// Staff routes need the admin scope as well as the role, so only staff
+// logins get it.
+func LoginScopes(role string) []string {
+	if role != RoleUser && ValidRole(role) {
+		return append(slices.Clip(DefaultScopes), ScopeAdmin)
+	}
+	return DefaultScopes
+}
+
+// OAuthScopes are the scopes third-party clients may request.
+var OAuthScopes = []string{ScopeChirpsWrite, ScopeProfileWrite, ScopeWebhooksWrite}
+
+type Claims struct {
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Role     string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Principal is the caller behind an access token. ClientID is set when the
+// token was issued to a third-party OAuth client on the user's behalf,
+// APIToken when it's a personal API token, and Role is only carried by
+// first-party access tokens.
+type Principal struct {
+	UserID   uuid.UUID
+	Scopes   []string
+	ClientID string
+	APIToken bool
+	Role     string
+}
+
+// FirstParty reports whether the principal is the user's own login session
+// rather than a token handed to a client or script. Changing credentials and
+// minting new tokens take a first-party session, so a leaked token can't be
+// turned into a takeover of the account.
+func (p Principal) FirstParty() bool {
+	return p.ClientID == "" && !p.APIToken
+}
+
+func (p Principal) HasScopes(scopes ...string) bool {
+	for _, s := range scopes {
+		if !slices.Contains(p.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
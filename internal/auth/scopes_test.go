@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testKeySet(t *testing.T) *KeySet {
+	t.Helper()
+	keys, err := LoadKeySet(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = keys.Rotate("ES256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestLoginScopes(t *testing.T) {
+	keys := testKeySet(t)
+	for _, tt := range []struct {
+		role  string
+		admin bool
+	}{
+		{RoleUser, false},
+		{RoleModerator, true},
+		{RoleAdmin, true},
+		{"root", false},
+	} {
+		token, err := MakeJWT(uuid.New(), tt.role, LoginScopes(tt.role), keys, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := ValidateJWT(token, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.HasScopes(DefaultScopes...) || p.HasScopes(ScopeAdmin) != tt.admin {
+			t.Errorf("role %q: scopes %v", tt.role, p.Scopes)
+		}
+	}
+	if slices.Contains(DefaultScopes, ScopeAdmin) {
+		t.Errorf("DefaultScopes = %v, picked up the admin scope", DefaultScopes)
+	}
+
+	// A client token asking for the admin scope still acts as a plain user.
+	token, err := MakeClientJWT(uuid.New(), "client", []string{ScopeAdmin}, keys, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ValidateJWT(token, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.HasRole(RoleModerator) {
+		t.Errorf("client token has role %q", p.Role)
+	}
+}
+
+func TestFirstParty(t *testing.T) {
+	keys := testKeySet(t)
+	session, err := MakeJWT(uuid.New(), RoleAdmin, LoginScopes(RoleAdmin), keys, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := MakeClientJWT(uuid.New(), "client", OAuthScopes, keys, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ValidateJWT(session, keys)
+	if err != nil || !p.FirstParty() {
+		t.Errorf("login session: FirstParty() = false, %v", err)
+	}
+	p, err = ValidateJWT(client, keys)
+	if err != nil || p.FirstParty() {
+		t.Errorf("client token: FirstParty() = true, %v", err)
+	}
+	// Personal API tokens with every scope still aren't a session.
+	p = Principal{UserID: uuid.New(), Scopes: KnownScopes, APIToken: true, Role: RoleUser}
+	if p.FirstParty() {
+		t.Error("API token: FirstParty() = true")
+	}
+}
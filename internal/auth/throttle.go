@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"time"
+)
+
+const (
+	LOCKOUT_THRESHOLD = 5
+	LOCKOUT_BASE      = 30 * time.Second
+	LOCKOUT_MAX       = time.Hour
+)
+
+// LockoutDuration returns how long to lock out a key after the given number
+// of consecutive failures, doubling from LOCKOUT_BASE once the threshold is
+// reached.
+func LockoutDuration(failures int) time.Duration {
+	if failures < LOCKOUT_THRESHOLD {
+		return 0
+	}
+	d := LOCKOUT_BASE
+	for i := LOCKOUT_THRESHOLD; i < failures; i++ {
+		d *= 2
+		if d >= LOCKOUT_MAX {
+			return LOCKOUT_MAX
+		}
+	}
+	return d
+}
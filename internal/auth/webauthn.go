@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+)
+
+type PasskeyCredential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+func MakeWebAuthnChallenge() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func DecodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// ParseClientData checks the ceremony type and origin of clientDataJSON and
+// returns the challenge it signs, which the caller must match against an
+// outstanding challenge.
+func ParseClientData(clientDataJSON []byte, ceremony, origin string) (string, error) {
+	cd := clientData{}
+	err := json.Unmarshal(clientDataJSON, &cd)
+	if err != nil {
+		return "", err
+	}
+	if cd.Type != ceremony {
+		return "", errors.New("wrong ceremony type")
+	}
+	if cd.Origin != origin {
+		return "", errors.New("wrong origin")
+	}
+	if len(cd.Challenge) == 0 {
+		return "", errors.New("missing challenge")
+	}
+	return strings.TrimRight(cd.Challenge, "="), nil
+}
+
+// VerifyRegistration checks an attestation object with the "none" format and
+// returns the credential it attests to.
+func VerifyRegistration(attestationObject []byte, rpID string) (PasskeyCredential, error) {
+	v, _, err := cborDecode(attestationObject)
+	if err != nil {
+		return PasskeyCredential{}, err
+	}
+	att, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return PasskeyCredential{}, errors.New("bad attestation object")
+	}
+	if f, _ := att["fmt"].(string); f != "none" {
+		return PasskeyCredential{}, errors.New("unsupported attestation format")
+	}
+	if stmt, ok := att["attStmt"].(map[interface{}]interface{}); !ok || len(stmt) != 0 {
+		return PasskeyCredential{}, errors.New("unexpected attestation statement")
+	}
+	authData, ok := att["authData"].([]byte)
+	if !ok {
+		return PasskeyCredential{}, errors.New("missing authenticator data")
+	}
+
+	flags, count, err := checkAuthData(authData, rpID)
+	if err != nil {
+		return PasskeyCredential{}, err
+	}
+	if flags&flagAttested == 0 {
+		return PasskeyCredential{}, errors.New("no attested credential data")
+	}
+
+	rest := authData[37:]
+	if len(rest) < 18 {
+		return PasskeyCredential{}, errors.New("short attested credential data")
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || len(rest) < idLen {
+		return PasskeyCredential{}, errors.New("bad credential id")
+	}
+	id := append([]byte{}, rest[:idLen]...)
+	rest = rest[idLen:]
+
+	_, n, err := cborDecode(rest)
+	if err != nil {
+		return PasskeyCredential{}, err
+	}
+	key := append([]byte{}, rest[:n]...)
+	_, err = parseCOSEKey(key)
+	if err != nil {
+		return PasskeyCredential{}, err
+	}
+
+	return PasskeyCredential{ID: id, PublicKey: key, SignCount: count}, nil
+}
+
+// VerifyAssertion checks an assertion signature against a stored COSE public
+// key and returns the authenticator's new signature counter.
+func VerifyAssertion(publicKey, authData, clientDataJSON, signature []byte, rpID string) (uint32, error) {
+	_, count, err := checkAuthData(authData, rpID)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	hash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), hash[:]...)
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(k, digest[:], signature) {
+			return 0, errors.New("bad signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, signed, signature) {
+			return 0, errors.New("bad signature")
+		}
+	default:
+		return 0, errors.New("unsupported key type")
+	}
+	return count, nil
+}
+
+func checkAuthData(authData []byte, rpID string) (byte, uint32, error) {
+	if len(authData) < 37 {
+		return 0, 0, errors.New("short authenticator data")
+	}
+	rpHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(authData[:32], rpHash[:]) {
+		return 0, 0, errors.New("wrong relying party")
+	}
+	flags := authData[32]
+	if flags&flagUserPresent == 0 {
+		return 0, 0, errors.New("user not present")
+	}
+	if flags&flagUserVerified == 0 {
+		return 0, 0, errors.New("user not verified")
+	}
+	return flags, binary.BigEndian.Uint32(authData[33:37]), nil
+}
+
+func parseCOSEKey(b []byte) (interface{}, error) {
+	v, _, err := cborDecode(b)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("bad cose key")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+	crv, _ := m[int64(-1)].(int64)
+	x, _ := m[int64(-2)].([]byte)
+
+	switch {
+	case kty == 2 && alg == coseAlgES256 && crv == 1:
+		y, _ := m[int64(-3)].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("bad ec2 key")
+		}
+		_, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, errors.New("bad ec2 key")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case kty == 1 && alg == coseAlgEdDSA && crv == 6:
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("bad okp key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errors.New("unsupported cose key")
+}
@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	mrand "math/rand"
+	"testing"
+)
+
+const testRPID = "chirpy.example"
+const testOrigin = "https://chirpy.example"
+
+// cborHead encodes a CBOR initial byte and argument.
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+}
+
+func cborInt(i int64) []byte {
+	if i < 0 {
+		return cborHead(1, uint64(-1-i))
+	}
+	return cborHead(0, uint64(i))
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, uint64(len(b))), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, uint64(len(s))), s...)
+}
+
+// cborMap encodes alternating, already encoded keys and values.
+func cborMap(kv ...[]byte) []byte {
+	out := cborHead(5, uint64(len(kv)/2))
+	for _, b := range kv {
+		out = append(out, b...)
+	}
+	return out
+}
+
+// softAuthenticator is a passkey held in memory, standing in for a real
+// authenticator in the registration and login ceremonies.
+type softAuthenticator struct {
+	id      []byte
+	cose    []byte
+	sign    func(msg []byte) []byte
+	counter uint32
+}
+
+func newES256Authenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := priv.X.FillBytes(make([]byte, 32))
+	y := priv.Y.FillBytes(make([]byte, 32))
+	return &softAuthenticator{
+		id:   []byte("es256-credential"),
+		cose: cborMap(cborInt(1), cborInt(2), cborInt(3), cborInt(coseAlgES256), cborInt(-1), cborInt(1), cborInt(-2), cborBytes(x), cborInt(-3), cborBytes(y)),
+		sign: func(msg []byte) []byte {
+			digest := sha256.Sum256(msg)
+			sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sig
+		},
+	}
+}
+
+func newEd25519Authenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{
+		id:   []byte("ed25519-credential"),
+		cose: cborMap(cborInt(1), cborInt(1), cborInt(3), cborInt(coseAlgEdDSA), cborInt(-1), cborInt(6), cborInt(-2), cborBytes(pub)),
+		sign: func(msg []byte) []byte {
+			return ed25519.Sign(priv, msg)
+		},
+	}
+}
+
+func (a *softAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(rpID))
+	out := append([]byte{}, rpHash[:]...)
+	if attested {
+		flags |= flagAttested
+	}
+	out = append(out, flags)
+	out = binary.BigEndian.AppendUint32(out, a.counter)
+	if attested {
+		out = append(out, make([]byte, 16)...)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.id)))
+		out = append(out, a.id...)
+		out = append(out, a.cose...)
+	}
+	return out
+}
+
+func (a *softAuthenticator) attestationObject(authData []byte) []byte {
+	return cborMap(cborText("fmt"), cborText("none"), cborText("attStmt"), cborMap(), cborText("authData"), cborBytes(authData))
+}
+
+func (a *softAuthenticator) register(rpID string, flags byte) []byte {
+	return a.attestationObject(a.authData(rpID, flags, true))
+}
+
+// assert returns the authenticator data, client data and signature of a
+// login.
+func (a *softAuthenticator) assert(rpID string, flags byte) ([]byte, []byte, []byte) {
+	a.counter++
+	authData := a.authData(rpID, flags, false)
+	clientDataJSON, _ := json.Marshal(clientData{Type: "webauthn.get", Challenge: "challenge", Origin: testOrigin})
+	hash := sha256.Sum256(clientDataJSON)
+	return authData, clientDataJSON, a.sign(append(append([]byte{}, authData...), hash[:]...))
+}
+
+func softAuthenticators(t *testing.T) map[string]*softAuthenticator {
+	return map[string]*softAuthenticator{
+		"ES256":   newES256Authenticator(t),
+		"Ed25519": newEd25519Authenticator(t),
+	}
+}
+
+func TestWebAuthnCeremonies(t *testing.T) {
+	for name, a := range softAuthenticators(t) {
+		t.Run(name, func(t *testing.T) {
+			cred, err := VerifyRegistration(a.register(testRPID, flagUserPresent|flagUserVerified), testRPID)
+			if err != nil {
+				t.Fatalf("VerifyRegistration: %v", err)
+			}
+			if !bytes.Equal(cred.ID, a.id) || !bytes.Equal(cred.PublicKey, a.cose) {
+				t.Fatalf("VerifyRegistration returned %+v", cred)
+			}
+
+			for want := uint32(1); want <= 2; want++ {
+				authData, clientDataJSON, sig := a.assert(testRPID, flagUserPresent|flagUserVerified)
+				count, err := VerifyAssertion(cred.PublicKey, authData, clientDataJSON, sig, testRPID)
+				if err != nil {
+					t.Fatalf("VerifyAssertion: %v", err)
+				}
+				if count != want {
+					t.Errorf("VerifyAssertion count = %d, want %d", count, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	for name, a := range softAuthenticators(t) {
+		t.Run(name, func(t *testing.T) {
+			good := a.authData(testRPID, flagUserPresent|flagUserVerified, true)
+			tests := map[string][]byte{
+				"wrong rpIdHash":   a.register("evil.example", flagUserPresent|flagUserVerified),
+				"no UP flag":       a.register(testRPID, flagUserVerified),
+				"no UV flag":       a.register(testRPID, flagUserPresent),
+				"not attested":     a.attestationObject(a.authData(testRPID, flagUserPresent|flagUserVerified, false)),
+				"packed format":    cborMap(cborText("fmt"), cborText("packed"), cborText("attStmt"), cborMap(), cborText("authData"), cborBytes(good)),
+				"attStmt present":  cborMap(cborText("fmt"), cborText("none"), cborText("attStmt"), cborMap(cborText("sig"), cborBytes([]byte{1})), cborText("authData"), cborBytes(good)),
+				"missing authData": cborMap(cborText("fmt"), cborText("none"), cborText("attStmt"), cborMap()),
+				"not a map":        cborBytes(good),
+				"bad cose key":     a.attestationObject(append(good[:len(good)-len(a.cose)], cborMap(cborInt(1), cborInt(2))...)),
+			}
+			for tname, obj := range tests {
+				_, err := VerifyRegistration(obj, testRPID)
+				if err == nil {
+					t.Errorf("%s: VerifyRegistration succeeded", tname)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	for name, a := range softAuthenticators(t) {
+		t.Run(name, func(t *testing.T) {
+			cred, err := VerifyRegistration(a.register(testRPID, flagUserPresent|flagUserVerified), testRPID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authData, clientDataJSON, sig := a.assert("evil.example", flagUserPresent|flagUserVerified)
+			if _, err := VerifyAssertion(cred.PublicKey, authData, clientDataJSON, sig, testRPID); err == nil {
+				t.Error("wrong rpIdHash: VerifyAssertion succeeded")
+			}
+			authData, clientDataJSON, sig = a.assert(testRPID, flagUserVerified)
+			if _, err := VerifyAssertion(cred.PublicKey, authData, clientDataJSON, sig, testRPID); err == nil {
+				t.Error("no UP flag: VerifyAssertion succeeded")
+			}
+			authData, clientDataJSON, sig = a.assert(testRPID, flagUserPresent)
+			if _, err := VerifyAssertion(cred.PublicKey, authData, clientDataJSON, sig, testRPID); err == nil {
+				t.Error("no UV flag: VerifyAssertion succeeded")
+			}
+
+			authData, clientDataJSON, sig = a.assert(testRPID, flagUserPresent|flagUserVerified)
+			tampered := append([]byte{}, sig...)
+			tampered[len(tampered)-1] ^= 0xff
+			if _, err := VerifyAssertion(cred.PublicKey, authData, clientDataJSON, tampered, testRPID); err == nil {
+				t.Error("bad signature: VerifyAssertion succeeded")
+			}
+			otherClientData := bytes.Replace(clientDataJSON, []byte("challenge"), []byte("Challenge"), 1)
+			if _, err := VerifyAssertion(cred.PublicKey, authData, otherClientData, sig, testRPID); err == nil {
+				t.Error("changed client data: VerifyAssertion succeeded")
+			}
+			if _, err := VerifyAssertion(cred.PublicKey, authData[:36], clientDataJSON, sig, testRPID); err == nil {
+				t.Error("short authData: VerifyAssertion succeeded")
+			}
+
+			for oname, other := range softAuthenticators(t) {
+				if _, err := VerifyAssertion(other.cose, authData, clientDataJSON, sig, testRPID); err == nil {
+					t.Errorf("%s key: VerifyAssertion succeeded", oname)
+				}
+			}
+		})
+	}
+}
+
+func TestParseClientData(t *testing.T) {
+	cd, _ := json.Marshal(clientData{Type: "webauthn.create", Challenge: "abc=", Origin: testOrigin})
+	challenge, err := ParseClientData(cd, "webauthn.create", testOrigin)
+	if err != nil || challenge != "abc" {
+		t.Errorf("ParseClientData = %q, %v", challenge, err)
+	}
+	if _, err := ParseClientData(cd, "webauthn.get", testOrigin); err == nil {
+		t.Error("wrong ceremony: ParseClientData succeeded")
+	}
+	if _, err := ParseClientData(cd, "webauthn.create", "https://evil.example"); err == nil {
+		t.Error("wrong origin: ParseClientData succeeded")
+	}
+}
+
+// Every input below must be refused with an error rather than a panic or a
+// huge allocation.
+func TestMaliciousCBOR(t *testing.T) {
+	a := newES256Authenticator(t)
+	obj := a.register(testRPID, flagUserPresent|flagUserVerified)
+	for i := 0; i < len(obj); i++ {
+		if _, err := VerifyRegistration(obj[:i], testRPID); err == nil {
+			t.Errorf("VerifyRegistration accepted the object truncated to %d bytes", i)
+		}
+	}
+	for i := 0; i < len(a.cose); i++ {
+		if _, err := parseCOSEKey(a.cose[:i]); err == nil {
+			t.Errorf("parseCOSEKey accepted the key truncated to %d bytes", i)
+		}
+	}
+
+	malicious := map[string][]byte{
+		"empty":               {},
+		"huge byte string":    {0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"huge text string":    {0x7b, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"huge array":          {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"huge map":            {0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"overflowing integer": {0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"overflowing negint":  {0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"indefinite length":   {0x5f, 0x41, 0x00, 0xff},
+		"reserved info":       {0x1c},
+		"tag":                 {0xc0, 0x60},
+		"float":               {0xfa, 0x00, 0x00, 0x00, 0x00},
+		"array map key":       {0xa1, 0x80, 0x00},
+		"map map key":         {0xa1, 0xa0, 0x00},
+		"deep nesting":        bytes.Repeat([]byte{0x81}, 10000),
+		"truncated head":      {0x19, 0x01},
+	}
+	for name, b := range malicious {
+		if _, _, err := cborDecode(b); err == nil {
+			t.Errorf("%s: cborDecode succeeded", name)
+		}
+		if _, err := VerifyRegistration(b, testRPID); err == nil {
+			t.Errorf("%s: VerifyRegistration succeeded", name)
+		}
+		if _, err := parseCOSEKey(b); err == nil {
+			t.Errorf("%s: parseCOSEKey succeeded", name)
+		}
+	}
+
+	// Random corruptions of a valid object must never panic.
+	rng := mrand.New(mrand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		b := append([]byte{}, obj...)
+		for j := 0; j < 1+rng.Intn(4); j++ {
+			b[rng.Intn(len(b))] = byte(rng.Intn(256))
+		}
+		VerifyRegistration(b, testRPID)
+		parseCOSEKey(b[rng.Intn(len(b)):])
+	}
+}
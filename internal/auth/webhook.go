@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook signatures are HMAC-SHA256 over "<timestamp>.<body>", sent as
+// "v1=<hex>". A sender rotating secrets signs with each active one and
+// lists every signature, comma-separated.
+const WebhookSignatureVersion = "v1"
+
+var ErrWebhookTimestamp = errors.New("webhook timestamp outside tolerance")
+var ErrWebhookSignature = errors.New("no matching webhook signature")
+
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return WebhookSignatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks that one of the signatures in header was made over
+// body and timestamp with one of secrets, and that timestamp is within
+// tolerance of now either way, so captured requests can't be replayed later.
+func VerifyWebhook(timestamp, header string, body []byte, secrets []string, tolerance time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(strings.TrimSpace(timestamp), 10, 64)
+	if err != nil {
+		return ErrWebhookTimestamp
+	}
+	skew := now.Sub(time.Unix(ts, 0))
+	if skew > tolerance || skew < -tolerance {
+		return ErrWebhookTimestamp
+	}
+
+	for _, sig := range strings.Split(header, ",") {
+		sig = strings.TrimSpace(sig)
+		if !strings.HasPrefix(sig, WebhookSignatureVersion+"=") {
+			continue
+		}
+		for _, secret := range secrets {
+			if hmac.Equal([]byte(sig), []byte(SignWebhook(secret, ts, body))) {
+				return nil
+			}
+		}
+	}
+	return ErrWebhookSignature
+}
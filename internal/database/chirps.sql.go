@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -16,7 +17,7 @@ INSERT INTO chirps (id, created_at, updated_at, body, user_id)
 VALUES (
     gen_random_uuid(), NOW(), NOW(), $1, $2
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, edited_at, deleted_at, purge_after, deletion_decision_id
 `
 
 type CreateChirpParams struct {
@@ -33,6 +34,10 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.PurgeAfter,
+		&i.DeletionDecisionID,
 	)
 	return i, err
 }
@@ -57,13 +62,48 @@ func (q *Queries) DeleteChirps(ctx context.Context) error {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, edited_at, deleted_at, purge_after, deletion_decision_id FROM chirps
+WHERE id = $1 AND deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM account_deletions WHERE account_deletions.user_id = chirps.user_id
+) AND NOT EXISTS (
+    SELECT 1 FROM hidden_chirps WHERE hidden_chirps.chirp_id = chirps.id
+) AND (chirps.user_id = $2 OR (NOT EXISTS (
+    SELECT 1 FROM limited_chirps WHERE limited_chirps.chirp_id = chirps.id
+) AND NOT EXISTS (
+    SELECT 1 FROM user_visibility WHERE user_visibility.user_id = chirps.user_id
+)))
 ORDER BY created_at
 `
 
-func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirp, id)
+type GetChirpParams struct {
+	ID       uuid.UUID     `json:"id"`
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetChirp(ctx context.Context, arg GetChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.PurgeAfter,
+		&i.DeletionDecisionID,
+	)
+	return i, err
+}
+
+const getChirpIncludingHidden = `-- name: GetChirpIncludingHidden :one
+SELECT id, created_at, updated_at, body, user_id, edited_at, deleted_at, purge_after, deletion_decision_id FROM chirps
+WHERE id = $1
+`
+
+func (q *Queries) GetChirpIncludingHidden(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpIncludingHidden, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -71,17 +111,32 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.PurgeAfter,
+		&i.DeletionDecisionID,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, edited_at, deleted_at, purge_after, deletion_decision_id FROM chirps
+WHERE deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM account_deletions WHERE account_deletions.user_id = chirps.user_id
+) AND NOT EXISTS (
+    SELECT 1 FROM hidden_chirps WHERE hidden_chirps.chirp_id = chirps.id
+) AND (chirps.user_id = $1 OR (NOT EXISTS (
+    SELECT 1 FROM limited_chirps WHERE limited_chirps.chirp_id = chirps.id
+) AND NOT EXISTS (
+    SELECT 1 FROM user_visibility WHERE user_visibility.user_id = chirps.user_id
+)))
 ORDER BY created_at
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+// Limited chirps and the chirps of limited and shadow-banned authors are
+// only shown to the author, here and in GetChirpsUser and GetChirp.
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -95,6 +150,10 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.PurgeAfter,
+			&i.DeletionDecisionID,
 		); err != nil {
 			return nil, err
 		}
@@ -110,13 +169,80 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsUser = `-- name: GetChirpsUser :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
+SELECT id, created_at, updated_at, body, user_id, edited_at, deleted_at, purge_after, deletion_decision_id FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM account_deletions WHERE account_deletions.user_id = chirps.user_id
+) AND NOT EXISTS (
+    SELECT 1 FROM hidden_chirps WHERE hidden_chirps.chirp_id = chirps.id
+) AND (chirps.user_id = $2 OR (NOT EXISTS (
+    SELECT 1 FROM limited_chirps WHERE limited_chirps.chirp_id = chirps.id
+) AND NOT EXISTS (
+    SELECT 1 FROM user_visibility WHERE user_visibility.user_id = chirps.user_id
+)))
 ORDER BY created_at
 `
 
-func (q *Queries) GetChirpsUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsUser, userID)
+type GetChirpsUserParams struct {
+	UserID   uuid.UUID     `json:"user_id"`
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetChirpsUser(ctx context.Context, arg GetChirpsUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsUser, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.PurgeAfter,
+			&i.DeletionDecisionID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsUserPage = `-- name: GetChirpsUserPage :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, deleted_at, purge_after, deletion_decision_id FROM chirps
+WHERE user_id = $1 AND (created_at > $2 OR (created_at = $2 AND id > $3))
+ORDER BY created_at, id
+LIMIT $4
+`
+
+type GetChirpsUserPageParams struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID `json:"id"`
+	Limit     int32     `json:"limit"`
+}
+
+// Pages through everything still held of a user's chirps, soft-deleted and
+// held ones included.
+func (q *Queries) GetChirpsUserPage(ctx context.Context, arg GetChirpsUserPageParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsUserPage,
+		arg.UserID,
+		arg.CreatedAt,
+		arg.ID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -130,6 +256,10 @@ func (q *Queries) GetChirpsUser(ctx context.Context, userID uuid.UUID) ([]Chirp,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.PurgeAfter,
+			&i.DeletionDecisionID,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// visibleChirps reports which of chirps viewer can see through each of the
+// public read queries, failing the test if the queries disagree.
+func visibleChirps(t *testing.T, q *Queries, viewer uuid.NullUUID, chirps ...Chirp) map[uuid.UUID]bool {
+	t.Helper()
+	ctx := context.Background()
+
+	feed, err := q.GetChirps(ctx, viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inFeed := map[uuid.UUID]bool{}
+	for _, c := range feed {
+		inFeed[c.ID] = true
+	}
+
+	visible := map[uuid.UUID]bool{}
+	for _, chirp := range chirps {
+		byAuthor, err := q.GetChirpsUser(ctx, GetChirpsUserParams{UserID: chirp.UserID, ViewerID: viewer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		inAuthor := false
+		for _, c := range byAuthor {
+			inAuthor = inAuthor || c.ID == chirp.ID
+		}
+
+		_, err = q.GetChirp(ctx, GetChirpParams{ID: chirp.ID, ViewerID: viewer})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			t.Fatal(err)
+		}
+		byID := err == nil
+
+		if inFeed[chirp.ID] != inAuthor || inAuthor != byID {
+			t.Errorf("chirp %q: in feed %v, by author %v, by ID %v", chirp.Body, inFeed[chirp.ID], inAuthor, byID)
+		}
+		visible[chirp.ID] = byID
+	}
+	return visible
+}
+
+func TestChirpVisibility(t *testing.T) {
+	q := testQueries(t)
+	ctx := context.Background()
+
+	limited := testUser(t, q, "limited@example.com")
+	banned := testUser(t, q, "banned@example.com")
+	normal := testUser(t, q, "normal@example.com")
+	stranger := testUser(t, q, "stranger@example.com")
+
+	for user, state := range map[uuid.UUID]string{limited.ID: "limited", banned.ID: "shadow_banned"} {
+		_, err := q.SetUserVisibility(ctx, SetUserVisibilityParams{UserID: user, State: state})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fromLimited := testChirp(t, q, limited, "from a limited account")
+	fromBanned := testChirp(t, q, banned, "from a shadow-banned account")
+	plain := testChirp(t, q, normal, "plain")
+	spammy := testChirp(t, q, normal, "limited by the spam checks")
+	err := q.LimitChirp(ctx, spammy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := []Chirp{fromLimited, fromBanned, plain, spammy}
+
+	as := func(u User) uuid.NullUUID { return uuid.NullUUID{UUID: u.ID, Valid: true} }
+	for name, tt := range map[string]struct {
+		viewer uuid.NullUUID
+		want   []Chirp
+	}{
+		"anonymous":                   {uuid.NullUUID{}, []Chirp{plain}},
+		"third party":                 {as(stranger), []Chirp{plain}},
+		"limited author":              {as(limited), []Chirp{fromLimited, plain}},
+		"shadow-banned author":        {as(banned), []Chirp{fromBanned, plain}},
+		"author of the limited chirp": {as(normal), []Chirp{plain, spammy}},
+	} {
+		visible := visibleChirps(t, q, tt.viewer, all...)
+		want := map[uuid.UUID]bool{}
+		for _, c := range tt.want {
+			want[c.ID] = true
+		}
+		for _, c := range all {
+			if visible[c.ID] != want[c.ID] {
+				t.Errorf("%s: sees %q = %v, want %v", name, c.Body, visible[c.ID], want[c.ID])
+			}
+		}
+	}
+}
+
+func TestSoftDeleteChirp(t *testing.T) {
+	q := testQueries(t)
+	ctx := context.Background()
+
+	author := testUser(t, q, "author@example.com")
+	viewer := uuid.NullUUID{UUID: author.ID, Valid: true}
+	restorable := testChirp(t, q, author, "restorable")
+	expired := testChirp(t, q, author, "past its restore window")
+	kept := testChirp(t, q, author, "kept")
+
+	for chirp, purgeAfter := range map[uuid.UUID]time.Time{restorable.ID: time.Now().Add(time.Hour), expired.ID: time.Now().Add(-time.Hour)} {
+		err := q.SoftDeleteChirp(ctx, SoftDeleteChirpParams{PurgeAfter: purgeAfter, ChirpID: chirp})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	visible := visibleChirps(t, q, viewer, restorable, expired, kept)
+	if visible[restorable.ID] || visible[expired.ID] || !visible[kept.ID] {
+		t.Errorf("after deletion the author sees %v", visible)
+	}
+
+	for chirp, want := range map[uuid.UUID]int64{restorable.ID: 1, expired.ID: 0, kept.ID: 0} {
+		n, err := q.RestoreChirp(ctx, chirp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("RestoreChirp(%s) restored %d, want %d", chirp, n, want)
+		}
+	}
+	if !visibleChirps(t, q, viewer, restorable)[restorable.ID] {
+		t.Error("restored chirp is still hidden")
+	}
+
+	n, err := q.PurgeDeletedChirps(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("PurgeDeletedChirps() = %d, want 1", n)
+	}
+	_, err = q.GetChirpIncludingHidden(ctx, expired.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("purged chirp: %v", err)
+	}
+}
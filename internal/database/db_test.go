@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// testQueries runs the migrations into a schema of their own on the
+// database named by TEST_DB_URL, which is dropped when the test ends. Tests
+// that need it are skipped when TEST_DB_URL isn't set.
+func testQueries(t *testing.T) *Queries {
+	t.Helper()
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One connection, so the search path set below applies to every query.
+	db.SetMaxOpenConns(1)
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	_, err = db.Exec("CREATE SCHEMA " + schema + "; SET search_path TO " + schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DROP SCHEMA " + schema + " CASCADE")
+		db.Close()
+	})
+
+	migrations, err := filepath.Glob("../../sql/schema/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range migrations {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		_, err = db.Exec(up)
+		if err != nil {
+			t.Fatalf("%s: %s", filepath.Base(path), err)
+		}
+	}
+	return New(db)
+}
+
+func testUser(t *testing.T, q *Queries, email string) User {
+	t.Helper()
+	user, err := q.CreateUser(context.Background(), CreateUserParams{Email: email, HashedPassword: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func testChirp(t *testing.T, q *Queries, user User, body string) Chirp {
+	t.Helper()
+	chirp, err := q.CreateChirp(context.Background(), CreateChirpParams{Body: body, UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chirp
+}
@@ -6,26 +6,222 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AccountDeletion struct {
+	UserID      uuid.UUID `json:"user_id"`
+	RequestedAt time.Time `json:"requested_at"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+type ApiToken struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	UserID     uuid.UUID    `json:"user_id"`
+	Name       string       `json:"name"`
+	TokenHash  string       `json:"token_hash"`
+	Scopes     string       `json:"scopes"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+}
+
+type AuditLog struct {
+	Seq        int64         `json:"seq"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ActorID    uuid.NullUUID `json:"actor_id"`
+	Action     string        `json:"action"`
+	TargetType string        `json:"target_type"`
+	TargetID   string        `json:"target_id"`
+	Ip         string        `json:"ip"`
+	Details    string        `json:"details"`
+	PrevHash   string        `json:"prev_hash"`
+	Hash       string        `json:"hash"`
+}
+
 type Chirp struct {
+	ID                 uuid.UUID     `json:"id"`
+	CreatedAt          time.Time     `json:"created_at"`
+	UpdatedAt          time.Time     `json:"updated_at"`
+	Body               string        `json:"body"`
+	UserID             uuid.UUID     `json:"user_id"`
+	EditedAt           *time.Time    `json:"edited_at"`
+	DeletedAt          *time.Time    `json:"-"`
+	PurgeAfter         *time.Time    `json:"-"`
+	DeletionDecisionID uuid.NullUUID `json:"-"`
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type DataExport struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
 	UserID    uuid.UUID `json:"user_id"`
+	Status    string    `json:"status"`
+	Size      int64     `json:"size"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type FilterRule struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+}
+
+type HiddenChirp struct {
+	ChirpID    uuid.UUID     `json:"chirp_id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	DecisionID uuid.NullUUID `json:"decision_id"`
+}
+
+type LimitedChirp struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoginFailure struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	Email     string        `json:"email"`
+	UserID    uuid.NullUUID `json:"user_id"`
+	Ip        string        `json:"ip"`
+	Reason    string        `json:"reason"`
+}
+
+type LoginThrottle struct {
+	Key         string       `json:"key"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Failures    int32        `json:"failures"`
+	LockedUntil sql.NullTime `json:"locked_until"`
+}
+
+type ModerationDecision struct {
+	ID          uuid.UUID     `json:"id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ChirpID     uuid.UUID     `json:"chirp_id"`
+	AuthorID    uuid.NullUUID `json:"author_id"`
+	ModeratorID uuid.NullUUID `json:"moderator_id"`
+	Action      string        `json:"action"`
+	Note        string        `json:"note"`
+}
+
+type OauthClient struct {
+	ID           string         `json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	UserID       uuid.UUID      `json:"user_id"`
+	Name         string         `json:"name"`
+	SecretHash   sql.NullString `json:"secret_hash"`
+	RedirectUris string         `json:"redirect_uris"`
+}
+
+type OauthCode struct {
+	CodeHash      string    `json:"code_hash"`
+	CreatedAt     time.Time `json:"created_at"`
+	ClientID      string    `json:"client_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	RedirectUri   string    `json:"redirect_uri"`
+	Scopes        string    `json:"scopes"`
+	CodeChallenge string    `json:"code_challenge"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+type OauthGrant struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Scopes    string    `json:"scopes"`
+}
+
+type Passkey struct {
+	ID         string       `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	UserID     uuid.UUID    `json:"user_id"`
+	PublicKey  []byte       `json:"public_key"`
+	SignCount  int64        `json:"sign_count"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+}
+
+type RecoveryCode struct {
+	CodeHash  string       `json:"code_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	UserID    uuid.UUID    `json:"user_id"`
+	UsedAt    sql.NullTime `json:"used_at"`
 }
 
 type RefreshToken struct {
-	Token     string       `json:"token"`
+	Token     string         `json:"token"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	UserID    uuid.UUID      `json:"user_id"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	RevokedAt sql.NullTime   `json:"revoked_at"`
+	ClientID  sql.NullString `json:"client_id"`
+	Scopes    string         `json:"scopes"`
+}
+
+type Report struct {
+	ID         uuid.UUID     `json:"id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ChirpID    uuid.UUID     `json:"chirp_id"`
+	ReporterID uuid.NullUUID `json:"reporter_id"`
+	Category   string        `json:"category"`
+	Details    string        `json:"details"`
+	DecisionID uuid.NullUUID `json:"decision_id"`
+	ResolvedAt sql.NullTime  `json:"resolved_at"`
+}
+
+type SpamDecision struct {
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	ChirpID   uuid.UUID       `json:"chirp_id"`
+	UserID    uuid.NullUUID   `json:"user_id"`
+	Score     float64         `json:"score"`
+	Action    string          `json:"action"`
+	Signals   json.RawMessage `json:"signals"`
+}
+
+type Subscription struct {
+	UserID           uuid.UUID    `json:"user_id"`
+	Status           string       `json:"status"`
+	CurrentPeriodEnd sql.NullTime `json:"current_period_end"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+	LastEventAt      time.Time    `json:"last_event_at"`
+}
+
+type SubscriptionEvent struct {
+	ID        uuid.UUID    `json:"id"`
 	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
 	UserID    uuid.UUID    `json:"user_id"`
-	ExpiresAt time.Time    `json:"expires_at"`
-	RevokedAt sql.NullTime `json:"revoked_at"`
+	Event     string       `json:"event"`
+	Status    string       `json:"status"`
+	PeriodEnd sql.NullTime `json:"period_end"`
+}
+
+type TotpSecret struct {
+	UserID       uuid.UUID    `json:"user_id"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	Secret       string       `json:"secret"`
+	ConfirmedAt  sql.NullTime `json:"confirmed_at"`
+	LastUsedStep int64        `json:"last_used_step"`
 }
 
 type User struct {
@@ -35,4 +231,85 @@ type User struct {
 	Email          string    `json:"email"`
 	HashedPassword string    `json:"hashed_password"`
 	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	Role           string    `json:"role"`
+}
+
+type UserSuspension struct {
+	UserID      uuid.UUID     `json:"user_id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	SuspendedBy uuid.NullUUID `json:"suspended_by"`
+	Reason      string        `json:"reason"`
+}
+
+type UserVisibility struct {
+	UserID    uuid.UUID     `json:"user_id"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	State     string        `json:"state"`
+	SetBy     uuid.NullUUID `json:"set_by"`
+	Reason    string        `json:"reason"`
+}
+
+type UserWarning struct {
+	ID         uuid.UUID     `json:"id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UserID     uuid.UUID     `json:"user_id"`
+	DecisionID uuid.NullUUID `json:"decision_id"`
+	Reason     string        `json:"reason"`
+}
+
+type WebauthnChallenge struct {
+	Challenge string        `json:"challenge"`
+	CreatedAt time.Time     `json:"created_at"`
+	UserID    uuid.NullUUID `json:"user_id"`
+	Ceremony  string        `json:"ceremony"`
+	ExpiresAt time.Time     `json:"expires_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID    `json:"id"`
+	CreatedAt      time.Time    `json:"created_at"`
+	EndpointID     uuid.UUID    `json:"endpoint_id"`
+	EventID        uuid.UUID    `json:"event_id"`
+	EventType      string       `json:"event_type"`
+	Payload        string       `json:"payload"`
+	Status         string       `json:"status"`
+	Attempts       int32        `json:"attempts"`
+	NextAttemptAt  time.Time    `json:"next_attempt_at"`
+	LastStatusCode int32        `json:"last_status_code"`
+	LastError      string       `json:"last_error"`
+	DeliveredAt    sql.NullTime `json:"delivered_at"`
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          uuid.UUID `json:"id"`
+	DeliveryID  uuid.UUID `json:"delivery_id"`
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  int32     `json:"status_code"`
+	Error       string    `json:"error"`
+	DurationMs  int32     `json:"duration_ms"`
+}
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID    `json:"id"`
+	CreatedAt           time.Time    `json:"created_at"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+	UserID              uuid.UUID    `json:"user_id"`
+	Url                 string       `json:"url"`
+	Secret              string       `json:"secret"`
+	EventTypes          string       `json:"event_types"`
+	ConsecutiveFailures int32        `json:"consecutive_failures"`
+	DisabledAt          sql.NullTime `json:"disabled_at"`
+}
+
+type WebhookEvent struct {
+	ID          string       `json:"id"`
+	Source      string       `json:"source"`
+	Event       string       `json:"event"`
+	Payload     string       `json:"payload"`
+	Status      string       `json:"status"`
+	Attempts    int32        `json:"attempts"`
+	LastError   string       `json:"last_error"`
+	ReceivedAt  time.Time    `json:"received_at"`
+	ProcessedAt sql.NullTime `json:"processed_at"`
+	ClaimedAt   time.Time    `json:"claimed_at"`
 }
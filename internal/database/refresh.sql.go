@@ -7,16 +7,54 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const createOAuthRefreshToken = `-- name: CreateOAuthRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, client_id, scopes)
+VALUES (
+    $1, NOW(), NOW(), $2, NOW() + interval '60' day, $3, $4
+)
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes
+`
+
+type CreateOAuthRefreshTokenParams struct {
+	Token    string         `json:"token"`
+	UserID   uuid.UUID      `json:"user_id"`
+	ClientID sql.NullString `json:"client_id"`
+	Scopes   string         `json:"scopes"`
+}
+
+func (q *Queries) CreateOAuthRefreshToken(ctx context.Context, arg CreateOAuthRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, createOAuthRefreshToken,
+		arg.Token,
+		arg.UserID,
+		arg.ClientID,
+		arg.Scopes,
+	)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.ClientID,
+		&i.Scopes,
+	)
+	return i, err
+}
+
 const createRefreshToken = `-- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
 VALUES (
     $1, NOW(), NOW(), $2, NOW() + interval '60' day
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes
 `
 
 type CreateRefreshTokenParams struct {
@@ -34,6 +72,8 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ClientID,
+		&i.Scopes,
 	)
 	return i, err
 }
@@ -48,7 +88,7 @@ func (q *Queries) DeleteRefreshTokens(ctx context.Context) error {
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens 
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes FROM refresh_tokens 
 WHERE token = $1
 `
 
@@ -62,10 +102,71 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshTok
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ClientID,
+		&i.Scopes,
 	)
 	return i, err
 }
 
+const getRefreshTokensUser = `-- name: GetRefreshTokensUser :many
+SELECT created_at, expires_at, revoked_at, client_id, scopes FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+type GetRefreshTokensUserRow struct {
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	RevokedAt sql.NullTime   `json:"revoked_at"`
+	ClientID  sql.NullString `json:"client_id"`
+	Scopes    string         `json:"scopes"`
+}
+
+func (q *Queries) GetRefreshTokensUser(ctx context.Context, userID uuid.UUID) ([]GetRefreshTokensUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRefreshTokensUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRefreshTokensUserRow
+	for rows.Next() {
+		var i GetRefreshTokensUserRow
+		if err := rows.Scan(
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.ClientID,
+			&i.Scopes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeOAuthRefreshTokens = `-- name: RevokeOAuthRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeOAuthRefreshTokensParams struct {
+	UserID   uuid.UUID      `json:"user_id"`
+	ClientID sql.NullString `json:"client_id"`
+}
+
+func (q *Queries) RevokeOAuthRefreshTokens(ctx context.Context, arg RevokeOAuthRefreshTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeOAuthRefreshTokens, arg.UserID, arg.ClientID)
+	return err
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens 
 SET updated_at = NOW(), revoked_at = NOW()
@@ -76,3 +177,14 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) error {
 	_, err := q.db.ExecContext(ctx, revokeRefreshToken, token)
 	return err
 }
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	return err
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUpsertSubscriptionOrder(t *testing.T) {
+	q := testQueries(t)
+	ctx := context.Background()
+	user := testUser(t, q, "member@example.com")
+
+	renewedAt := time.Now().Add(-time.Minute)
+	_, err := q.UpsertSubscription(ctx, UpsertSubscriptionParams{UserID: user.ID, Status: "active", EventAt: renewedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A payment failure from before the renewal arrives late.
+	_, err = q.UpsertSubscription(ctx, UpsertSubscriptionParams{UserID: user.ID, Status: "past_due", EventAt: renewedAt.Add(-time.Hour)})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("stale event: %v, want no row", err)
+	}
+	sub, err := q.GetSubscription(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status != "active" {
+		t.Errorf("status %q after a stale event, want active", sub.Status)
+	}
+
+	_, err = q.UpsertSubscription(ctx, UpsertSubscriptionParams{UserID: user.ID, Status: "canceled", EventAt: time.Now()})
+	if err != nil {
+		t.Errorf("newer event: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Package entitlements maps plan tiers to what accounts on them can do.
+package entitlements
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+type Plan string
+
+const (
+	PlanFree Plan = "free"
+	PlanRed  Plan = "chirpy_red"
+)
+
+// Duration reads as a Go duration string ("15m") in a plans file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Entitlements are the capabilities a plan grants. Handlers check these
+// rather than the plan itself, so perks can change without touching them.
+type Entitlements struct {
+	// ChirpLength is the most bytes a chirp body may hold.
+	ChirpLength int `json:"chirp_length"`
+	// EditWindow is how long after posting a chirp can still be edited.
+	EditWindow Duration `json:"edit_window"`
+	// ChirpRate replaces the spam rules' rate limit: this many chirps per
+	// rate window are normal. Zero keeps the rules' own limit.
+	ChirpRate int `json:"chirp_rate"`
+}
+
+type Plans map[Plan]Entitlements
+
+var DefaultPlans = Plans{
+	PlanFree: {
+		ChirpLength: 140,
+		EditWindow:  Duration(15 * time.Minute),
+	},
+	PlanRed: {
+		ChirpLength: 280,
+		EditWindow:  Duration(time.Hour),
+		ChirpRate:   30,
+	},
+}
+
+// LoadPlans reads a JSON plans file keyed by plan name. Plans and fields it
+// leaves out keep their default values.
+func LoadPlans(path string) (Plans, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[Plan]json.RawMessage
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := Plans{}
+	for plan, e := range DefaultPlans {
+		plans[plan] = e
+	}
+	for plan, msg := range raw {
+		e, ok := plans[plan]
+		if !ok {
+			e = DefaultPlans[PlanFree]
+		}
+		err = json.Unmarshal(msg, &e)
+		if err != nil {
+			return nil, err
+		}
+		plans[plan] = e
+	}
+	return plans, nil
+}
+
+// For returns what plan grants. Unknown plans get the free tier.
+func (p Plans) For(plan Plan) Entitlements {
+	e, ok := p[plan]
+	if !ok {
+		return p[PlanFree]
+	}
+	return e
+}
@@ -0,0 +1,63 @@
+package entitlements
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadPlans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plans.json")
+	err := os.WriteFile(path, []byte(`{
+		"chirpy_red": {"chirp_length": 500, "edit_window": "2h"},
+		"staff": {"chirp_rate": 100}
+	}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plans, err := LoadPlans(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Plans the file leaves out keep their defaults.
+	if plans[PlanFree] != DefaultPlans[PlanFree] {
+		t.Errorf("free plan = %+v, want %+v", plans[PlanFree], DefaultPlans[PlanFree])
+	}
+	// Fields it leaves out keep the plan's defaults.
+	red := DefaultPlans[PlanRed]
+	red.ChirpLength = 500
+	red.EditWindow = Duration(2 * time.Hour)
+	if plans[PlanRed] != red {
+		t.Errorf("red plan = %+v, want %+v", plans[PlanRed], red)
+	}
+	// New plans start from the free tier.
+	staff := DefaultPlans[PlanFree]
+	staff.ChirpRate = 100
+	if plans["staff"] != staff {
+		t.Errorf("staff plan = %+v, want %+v", plans["staff"], staff)
+	}
+	if DefaultPlans[PlanRed].ChirpLength != 280 {
+		t.Errorf("LoadPlans changed DefaultPlans: %+v", DefaultPlans[PlanRed])
+	}
+
+	for _, bad := range []string{`{"free": {"edit_window": "soon"}}`, `{"free": {"chirp_length": "long"}}`, `[]`} {
+		err = os.WriteFile(path, []byte(bad), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadPlans(path); err == nil {
+			t.Errorf("LoadPlans(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestFor(t *testing.T) {
+	if got := DefaultPlans.For(PlanRed); got != DefaultPlans[PlanRed] {
+		t.Errorf("For(%q) = %+v", PlanRed, got)
+	}
+	if got := DefaultPlans.For("platinum"); got != DefaultPlans[PlanFree] {
+		t.Errorf("For(unknown plan) = %+v, want the free tier", got)
+	}
+}
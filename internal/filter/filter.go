@@ -0,0 +1,140 @@
+// Package filter matches chirp text against moderator-managed word rules.
+package filter
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Action string
+
+const (
+	ActionMask   Action = "mask"
+	ActionReject Action = "reject"
+	ActionFlag   Action = "flag"
+)
+
+func ValidAction(a Action) bool {
+	return a == ActionMask || a == ActionReject || a == ActionFlag
+}
+
+type Rule struct {
+	Pattern string
+	Action  Action
+}
+
+type Filter struct {
+	rules map[string]Action
+}
+
+type Result struct {
+	// Body is the text with every masked word replaced by asterisks.
+	Body     string
+	Rejected bool
+	Flagged  bool
+	// Matches holds the normalized form of every rule that matched, word
+	// by word in the order of the text.
+	Matches []string
+}
+
+// New builds a filter from rules. When two rules normalize to the same
+// pattern the strictest action wins.
+func New(rules []Rule) *Filter {
+	f := &Filter{rules: map[string]Action{}}
+	for _, r := range rules {
+		p := Normalize(r.Pattern)
+		if p == "" || !ValidAction(r.Action) {
+			continue
+		}
+		if severity(r.Action) > severity(f.rules[p]) {
+			f.rules[p] = r.Action
+		}
+	}
+	return f
+}
+
+func severity(a Action) int {
+	switch a {
+	case ActionMask:
+		return 1
+	case ActionFlag:
+		return 2
+	case ActionReject:
+		return 3
+	}
+	return 0
+}
+
+// Check matches each whitespace-separated word of text against the rules.
+// A word matches if it normalizes to a rule's pattern as a whole, after
+// trimming surrounding punctuation, or in any of its punctuation-separated
+// parts.
+func (f *Filter) Check(text string) Result {
+	res := Result{}
+	words := strings.FieldsFunc(text, unicode.IsSpace)
+	if len(f.rules) == 0 || len(words) == 0 {
+		res.Body = text
+		return res
+	}
+
+	var b strings.Builder
+	rest := text
+	for _, word := range words {
+		i := strings.Index(rest, word)
+		b.WriteString(rest[:i])
+		rest = rest[i+len(word):]
+
+		masked := false
+		for _, pattern := range f.match(word) {
+			switch f.rules[pattern] {
+			case ActionMask:
+				masked = true
+			case ActionReject:
+				res.Rejected = true
+			case ActionFlag:
+				res.Flagged = true
+			}
+			res.Matches = append(res.Matches, pattern)
+		}
+		if masked {
+			b.WriteString(mask(word))
+		} else {
+			b.WriteString(word)
+		}
+	}
+	b.WriteString(rest)
+	res.Body = b.String()
+	return res
+}
+
+// match returns the patterns word matches, in sorted order so results
+// don't depend on map iteration.
+func (f *Filter) match(word string) []string {
+	candidates := []string{Normalize(word), Normalize(strings.TrimFunc(word, func(r rune) bool { return !isWordRune(r) }))}
+	for _, part := range strings.FieldsFunc(word, func(r rune) bool { return !isWordRune(r) }) {
+		candidates = append(candidates, Normalize(part))
+	}
+
+	matches := []string{}
+	for _, c := range candidates {
+		if _, ok := f.rules[c]; ok {
+			matches = append(matches, c)
+		}
+	}
+	slices.Sort(matches)
+	return slices.Compact(matches)
+}
+
+// mask replaces the word itself but keeps surrounding punctuation, so
+// "Kerfuffle!" becomes "****!".
+func mask(word string) string {
+	start := strings.IndexFunc(word, isWordRune)
+	end := strings.LastIndexFunc(word, isWordRune)
+	if start < 0 {
+		return "****"
+	}
+	_, size := utf8.DecodeRuneInString(word[end:])
+	return word[:start] + "****" + word[end+size:]
+}
@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"Kerfuffle":  "kerfuffle",
+		"KERFUFFLE":  "kerfuffle",
+		"k.e.r-f_u":  "kerfu",
+		"kërfüffle":  "kerfuffle",
+		"ke\u0301rf": "kerf",      // combining acute accent
+		"k\u200berf": "kerf",      // zero-width space
+		"ｋｅｒｆ":       "kerf",      // fullwidth forms
+		"кеrfuffle":  "kerfuffle", // Cyrillic к and е
+		"ѕhаrbеrt":   "sharbert",  // Cyrillic ѕ, а and е
+		"fοrnαx":     "fornax",    // Greek ο and α
+		"k3rfuffl3":  "kerfuffle",
+		"$h4rb3r7":   "sharbert",
+		"f0rn@x":     "fornax",
+		"!nf|n!t3":   "infinite",
+		"5p4m+r0ll":  "spamtroll",
+		"8r09":       "brog",
+		"   ":        "",
+		"...":        "",
+		"ok":         "ok",
+	} {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	f := New([]Rule{
+		{Pattern: "kerfuffle", Action: ActionMask},
+		{Pattern: "sharbert", Action: ActionMask},
+		{Pattern: "fornax", Action: ActionFlag},
+		{Pattern: "spam", Action: ActionReject},
+		// Normalizes to the same pattern; the stricter action wins.
+		{Pattern: "Sp@m", Action: ActionMask},
+	})
+
+	for _, tt := range []struct {
+		text     string
+		body     string
+		rejected bool
+		flagged  bool
+		matches  []string
+	}{
+		{"hello there", "hello there", false, false, nil},
+		{"what a kerfuffle!", "what a ****!", false, false, []string{"kerfuffle"}},
+		{"K3RFÜFFLE", "****", false, false, []string{"kerfuffle"}},
+		{"(sharbert)", "(****)", false, false, []string{"sharbert"}},
+		{"fοrnαx  time", "fοrnαx  time", false, true, []string{"fornax"}},
+		{"buy $p4m now", "buy $p4m now", true, false, []string{"spam"}},
+		// Parts of a punctuated word match on their own.
+		{"kerfuffle/sharbert", "****", false, false, []string{"kerfuffle", "sharbert"}},
+		{"sharbert/kerfuffle fornax", "**** fornax", false, true, []string{"kerfuffle", "sharbert", "fornax"}},
+	} {
+		res := f.Check(tt.text)
+		if res.Body != tt.body || res.Rejected != tt.rejected || res.Flagged != tt.flagged || !slices.Equal(res.Matches, tt.matches) {
+			t.Errorf("Check(%q) = %+v", tt.text, res)
+		}
+	}
+}
+
+func TestCheckOrderIsStable(t *testing.T) {
+	rules := []Rule{}
+	for _, p := range []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot"} {
+		rules = append(rules, Rule{Pattern: p, Action: ActionFlag})
+	}
+	f := New(rules)
+
+	text := "foxtrot-echo-delta-charlie-bravo-alpha"
+	want := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot"}
+	for range 50 {
+		if got := f.Check(text).Matches; !slices.Equal(got, want) {
+			t.Fatalf("Check(%q).Matches = %v, want %v", text, got, want)
+		}
+	}
+}
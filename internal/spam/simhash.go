@@ -0,0 +1,53 @@
+package spam
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// Simhash fingerprints text so that near-duplicate bodies land a small
+// Hamming distance apart. Features are the words and overlapping word
+// pairs, so a copy with a word or two changed stays close while unrelated
+// text that shares vocabulary does not.
+func Simhash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var v [64]int
+	addFeature := func(parts ...string) {
+		h := fnv.New64a()
+		for _, p := range parts {
+			h.Write([]byte(p))
+			h.Write([]byte{0})
+		}
+		sum := h.Sum64()
+		for b := 0; b < 64; b++ {
+			if sum&(1<<b) != 0 {
+				v[b]++
+			} else {
+				v[b]--
+			}
+		}
+	}
+	for i, w := range words {
+		addFeature(w)
+		if i+1 < len(words) {
+			addFeature(w, words[i+1])
+		}
+	}
+
+	var fp uint64
+	for b := 0; b < 64; b++ {
+		if v[b] > 0 {
+			fp |= 1 << b
+		}
+	}
+	return fp
+}
+
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
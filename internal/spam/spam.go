@@ -0,0 +1,195 @@
+// Package spam scores new chirps for signs of spam and abuse.
+package spam
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+)
+
+type Action string
+
+const (
+	ActionAllow Action = "allow"
+	ActionLimit Action = "limit"
+	ActionHold  Action = "hold"
+)
+
+// Duration reads as a Go duration string ("10m") in a rules file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Rules holds the weights and thresholds for every signal. A signal adds
+// its weight to the score when it fires; a zero weight disables it.
+type Rules struct {
+	// RateLimit chirps within RateWindow is normal; each one beyond that
+	// adds RateWeight.
+	RateWindow Duration `json:"rate_window"`
+	RateLimit  int      `json:"rate_limit"`
+	RateWeight float64  `json:"rate_weight"`
+
+	// Each recent chirp within DuplicateDistance bits of the new one adds
+	// DuplicateWeight.
+	DuplicateWindow   Duration `json:"duplicate_window"`
+	DuplicateDistance int      `json:"duplicate_distance"`
+	DuplicateWeight   float64  `json:"duplicate_weight"`
+
+	// LinkDensity is the share of words that are links above which
+	// LinkWeight is added.
+	LinkDensity float64 `json:"link_density"`
+	LinkWeight  float64 `json:"link_weight"`
+
+	// Accounts younger than NewAccountAge add NewAccountWeight.
+	NewAccountAge    Duration `json:"new_account_age"`
+	NewAccountWeight float64  `json:"new_account_weight"`
+
+	// Each mention beyond MentionLimit adds MentionWeight.
+	MentionLimit  int     `json:"mention_limit"`
+	MentionWeight float64 `json:"mention_weight"`
+
+	// Scores at or above HoldScore are held for review; at or above
+	// LimitScore the chirp is kept out of the public feed.
+	LimitScore float64 `json:"limit_score"`
+	HoldScore  float64 `json:"hold_score"`
+}
+
+var DefaultRules = Rules{
+	RateWindow:        Duration(10 * time.Minute),
+	RateLimit:         10,
+	RateWeight:        1,
+	DuplicateWindow:   Duration(24 * time.Hour),
+	DuplicateDistance: 10,
+	DuplicateWeight:   2,
+	LinkDensity:       0.3,
+	LinkWeight:        2,
+	NewAccountAge:     Duration(24 * time.Hour),
+	NewAccountWeight:  1,
+	MentionLimit:      5,
+	MentionWeight:     1,
+	LimitScore:        4,
+	HoldScore:         7,
+}
+
+// LoadRules reads a JSON rules file. Fields it leaves out keep their
+// default values.
+func LoadRules(path string) (Rules, error) {
+	rules := DefaultRules
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, err
+	}
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return Rules{}, err
+	}
+	return rules, nil
+}
+
+// Recent is a chirp the author posted shortly before the one being scored.
+type Recent struct {
+	CreatedAt time.Time
+	Body      string
+}
+
+type Input struct {
+	Body           string
+	AccountCreated time.Time
+	Recent         []Recent
+	Now            time.Time
+}
+
+type Signal struct {
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+	Weight float64 `json:"weight"`
+}
+
+type Decision struct {
+	Score   float64  `json:"score"`
+	Action  Action   `json:"action"`
+	Signals []Signal `json:"signals"`
+}
+
+func (r Rules) Score(in Input) Decision {
+	d := Decision{Signals: []Signal{}}
+	add := func(name string, value, weight float64) {
+		if weight <= 0 {
+			return
+		}
+		d.Signals = append(d.Signals, Signal{Name: name, Value: value, Weight: weight})
+		d.Score += weight
+	}
+
+	rate := 0
+	duplicates := 0
+	fp := Simhash(in.Body)
+	for _, c := range in.Recent {
+		age := in.Now.Sub(c.CreatedAt)
+		if age <= time.Duration(r.RateWindow) {
+			rate++
+		}
+		if age <= time.Duration(r.DuplicateWindow) && Distance(fp, Simhash(c.Body)) <= r.DuplicateDistance {
+			duplicates++
+		}
+	}
+	if rate >= r.RateLimit {
+		add("posting_rate", float64(rate), float64(rate-r.RateLimit+1)*r.RateWeight)
+	}
+	if duplicates > 0 {
+		add("duplicate_body", float64(duplicates), float64(duplicates)*r.DuplicateWeight)
+	}
+
+	words := strings.Fields(in.Body)
+	links, mentions := 0, 0
+	for _, w := range words {
+		lw := strings.ToLower(w)
+		if strings.HasPrefix(lw, "http://") || strings.HasPrefix(lw, "https://") || strings.HasPrefix(lw, "www.") {
+			links++
+		}
+		if len(w) > 1 && w[0] == '@' {
+			mentions++
+		}
+	}
+	if len(words) > 0 && links > 0 {
+		density := float64(links) / float64(len(words))
+		if density > r.LinkDensity {
+			add("link_density", density, r.LinkWeight)
+		}
+	}
+	if mentions > r.MentionLimit {
+		add("mention_burst", float64(mentions), float64(mentions-r.MentionLimit)*r.MentionWeight)
+	}
+
+	age := in.Now.Sub(in.AccountCreated)
+	if age < time.Duration(r.NewAccountAge) {
+		add("new_account", age.Hours(), r.NewAccountWeight)
+	}
+
+	switch {
+	case r.HoldScore > 0 && d.Score >= r.HoldScore:
+		d.Action = ActionHold
+	case r.LimitScore > 0 && d.Score >= r.LimitScore:
+		d.Action = ActionLimit
+	default:
+		d.Action = ActionAllow
+	}
+	return d
+}
@@ -0,0 +1,200 @@
+package spam
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+const testBody = "Had a lovely walk along the river with the dog this morning"
+
+// recent makes n recent chirps posted age ago, each unlike testBody and
+// each other.
+func recent(n int, age time.Duration) []Recent {
+	out := []Recent{}
+	for i := range n {
+		out = append(out, Recent{CreatedAt: testNow.Add(-age), Body: fmt.Sprintf("unrelated post %d", i)})
+	}
+	return out
+}
+
+func copies(n int, body string, age time.Duration) []Recent {
+	out := []Recent{}
+	for range n {
+		out = append(out, Recent{CreatedAt: testNow.Add(-age), Body: body})
+	}
+	return out
+}
+
+func TestScore(t *testing.T) {
+	old := testNow.Add(-30 * 24 * time.Hour)
+	for _, tt := range []struct {
+		name    string
+		rules   func(r *Rules)
+		in      Input
+		signals map[string]float64
+		action  Action
+	}{
+		{
+			name:   "clean",
+			in:     Input{Body: testBody, AccountCreated: old},
+			action: ActionAllow,
+		},
+		{
+			name:    "new account",
+			in:      Input{Body: testBody, AccountCreated: testNow.Add(-time.Hour)},
+			signals: map[string]float64{"new_account": 1},
+			action:  ActionAllow,
+		},
+		{
+			// A zero weight disables the signal entirely.
+			name:   "new account, signal off",
+			rules:  func(r *Rules) { r.NewAccountWeight = 0 },
+			in:     Input{Body: testBody, AccountCreated: testNow.Add(-time.Hour)},
+			action: ActionAllow,
+		},
+		{
+			name:   "posting rate under the limit",
+			in:     Input{Body: testBody, AccountCreated: old, Recent: recent(9, time.Minute)},
+			action: ActionAllow,
+		},
+		{
+			name:    "posting rate at the limit",
+			in:      Input{Body: testBody, AccountCreated: old, Recent: recent(10, time.Minute)},
+			signals: map[string]float64{"posting_rate": 1},
+			action:  ActionAllow,
+		},
+		{
+			name:    "posting rate over the limit",
+			in:      Input{Body: testBody, AccountCreated: old, Recent: recent(14, time.Minute)},
+			signals: map[string]float64{"posting_rate": 5},
+			action:  ActionLimit,
+		},
+		{
+			name:   "posting rate outside the window",
+			in:     Input{Body: testBody, AccountCreated: old, Recent: recent(14, 11*time.Minute)},
+			action: ActionAllow,
+		},
+		{
+			name:    "duplicate",
+			in:      Input{Body: testBody, AccountCreated: old, Recent: copies(1, testBody, time.Hour)},
+			signals: map[string]float64{"duplicate_body": 2},
+			action:  ActionAllow,
+		},
+		{
+			name:    "duplicate with different case and punctuation",
+			in:      Input{Body: testBody, AccountCreated: old, Recent: copies(2, strings.ToUpper(testBody)+"!!", time.Hour)},
+			signals: map[string]float64{"duplicate_body": 4},
+			action:  ActionLimit,
+		},
+		{
+			name:   "duplicate outside the window",
+			in:     Input{Body: testBody, AccountCreated: old, Recent: copies(3, testBody, 25*time.Hour)},
+			action: ActionAllow,
+		},
+		{
+			name:    "links",
+			in:      Input{Body: "deals https://a.example www.b.example", AccountCreated: old},
+			signals: map[string]float64{"link_density": 2},
+			action:  ActionAllow,
+		},
+		{
+			name:   "links under the density",
+			in:     Input{Body: "my thoughts on this, at https://a.example", AccountCreated: old},
+			action: ActionAllow,
+		},
+		{
+			name:    "mentions over the limit",
+			in:      Input{Body: "hi @a @b @c @d @e @f @g", AccountCreated: old},
+			signals: map[string]float64{"mention_burst": 2},
+			action:  ActionAllow,
+		},
+		{
+			name:   "mentions at the limit",
+			in:     Input{Body: "hi @a @b @c @d @e and @ and email@example.com", AccountCreated: old},
+			action: ActionAllow,
+		},
+		{
+			name:    "held",
+			in:      Input{Body: testBody, AccountCreated: testNow.Add(-time.Hour), Recent: copies(3, testBody, time.Minute)},
+			signals: map[string]float64{"duplicate_body": 6, "new_account": 1},
+			action:  ActionHold,
+		},
+		{
+			name:    "holding off",
+			rules:   func(r *Rules) { r.HoldScore = 0 },
+			in:      Input{Body: testBody, AccountCreated: testNow.Add(-time.Hour), Recent: copies(3, testBody, time.Minute)},
+			signals: map[string]float64{"duplicate_body": 6, "new_account": 1},
+			action:  ActionLimit,
+		},
+	} {
+		rules := DefaultRules
+		if tt.rules != nil {
+			tt.rules(&rules)
+		}
+		tt.in.Now = testNow
+		d := rules.Score(tt.in)
+
+		got := map[string]float64{}
+		score := 0.0
+		for _, s := range d.Signals {
+			got[s.Name] = s.Weight
+			score += s.Weight
+		}
+		if len(got) != len(tt.signals) {
+			t.Errorf("%s: signals %+v, want %v", tt.name, d.Signals, tt.signals)
+		}
+		for name, weight := range tt.signals {
+			if got[name] != weight {
+				t.Errorf("%s: signal %s weighs %v, want %v", tt.name, name, got[name], weight)
+			}
+		}
+		if d.Score != score || d.Action != tt.action {
+			t.Errorf("%s: score %v, action %s, want action %s", tt.name, d.Score, d.Action, tt.action)
+		}
+	}
+}
+
+func TestSimhash(t *testing.T) {
+	if d := Distance(Simhash(testBody), Simhash(strings.ToUpper(testBody)+"!")); d != 0 {
+		t.Errorf("case and punctuation changed the fingerprint by %d bits", d)
+	}
+	if d := Distance(Simhash(testBody), Simhash("Huge sale today on designer watches, visit our store")); d <= DefaultRules.DuplicateDistance {
+		t.Errorf("unrelated text is only %d bits away", d)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	err := os.WriteFile(path, []byte(`{"rate_window": "1h", "rate_limit": 3, "hold_score": 0}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := DefaultRules
+	want.RateWindow = Duration(time.Hour)
+	want.RateLimit = 3
+	want.HoldScore = 0
+	if rules != want {
+		t.Errorf("LoadRules() = %+v, want %+v", rules, want)
+	}
+
+	for _, bad := range []string{`{"rate_window": "soon"}`, `{"rate_limit": "3"}`, `[`} {
+		err = os.WriteFile(path, []byte(bad), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRules(path); err == nil {
+			t.Errorf("LoadRules(%s) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Package webhooks delivers signed event notifications to endpoints that
+// integrators register.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+)
+
+const (
+	HeaderEvent     = "Chirpy-Event"
+	HeaderDelivery  = "Chirpy-Delivery"
+	HeaderTimestamp = "Chirpy-Timestamp"
+	HeaderSignature = "Chirpy-Signature"
+)
+
+const SecretPrefix = "whsec_"
+
+// EventTypes are the events an endpoint can subscribe to.
+var EventTypes = []string{"chirp.created", "chirp.deleted", "user.upgraded"}
+
+// Retries start BaseDelay after the first failure and double from there, up
+// to MaxDelay.
+const (
+	BaseDelay = 30 * time.Second
+	MaxDelay  = 6 * time.Hour
+)
+
+// Only this much of a receiver's response is read before the connection is
+// let go.
+const maxResponseBytes = 64 * 1024
+
+func MakeSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return SecretPrefix + hex.EncodeToString(b), nil
+}
+
+// Backoff is how long to wait before the next attempt at a delivery that
+// has failed attempts times.
+func Backoff(attempts int) time.Duration {
+	d := BaseDelay
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= MaxDelay {
+			return MaxDelay
+		}
+	}
+	return d
+}
+
+type Delivery struct {
+	ID      string
+	Event   string
+	Payload []byte
+}
+
+type Result struct {
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+}
+
+// OK reports whether the receiver accepted the delivery.
+func (r Result) OK() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Message describes what went wrong with an attempt, or is empty if nothing
+// did.
+func (r Result) Message() string {
+	switch {
+	case r.Err != nil:
+		return r.Err.Error()
+	case !r.OK():
+		return fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode))
+	}
+	return ""
+}
+
+// Send posts a delivery to url, signed with secret the same way Polka signs
+// the webhooks it sends us. client should carry a timeout.
+func Send(ctx context.Context, client *http.Client, url, secret string, d Delivery, now time.Time) Result {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return Result{Err: err}
+	}
+	ts := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, auth.SignWebhook(secret, ts, d.Payload))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Err: err, Duration: time.Since(start)}
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+	resp.Body.Close()
+	return Result{StatusCode: resp.StatusCode, Duration: time.Since(start)}
+}
@@ -0,0 +1,589 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+)
+
+// localClient can reach httptest receivers, which listen on loopback.
+var localClient = NewClient(time.Second, true)
+
+func TestSendSignature(t *testing.T) {
+	secret, err := MakeSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, SecretPrefix) {
+		t.Errorf("MakeSecret() = %q, want prefix %q", secret, SecretPrefix)
+	}
+
+	payload := []byte(`{"id":"e1","type":"chirp.created"}`)
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(204)
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	res := Send(context.Background(), localClient, srv.URL, secret, Delivery{ID: "d1", Event: "chirp.created", Payload: payload}, now)
+	if !res.OK() {
+		t.Fatalf("Send() = %+v", res)
+	}
+	if string(body) != string(payload) {
+		t.Errorf("receiver got body %q", body)
+	}
+	if got.Header.Get(HeaderEvent) != "chirp.created" || got.Header.Get(HeaderDelivery) != "d1" || got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("receiver got headers %v", got.Header)
+	}
+
+	ts, sig := got.Header.Get(HeaderTimestamp), got.Header.Get(HeaderSignature)
+	err = auth.VerifyWebhook(ts, sig, body, []string{secret}, time.Minute, now)
+	if err != nil {
+		t.Errorf("signature doesn't verify: %v", err)
+	}
+	err = auth.VerifyWebhook(ts, sig, body, []string{SecretPrefix + "other"}, time.Minute, now)
+	if err == nil {
+		t.Error("signature verifies against the wrong secret")
+	}
+	err = auth.VerifyWebhook(ts, sig, append(body, ' '), []string{secret}, time.Minute, now)
+	if err == nil {
+		t.Error("signature verifies against a changed body")
+	}
+}
+
+func TestSendResults(t *testing.T) {
+	status := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	d := Delivery{ID: "d1", Event: "chirp.created", Payload: []byte(`{}`)}
+	for _, tt := range []struct {
+		status int
+		ok     bool
+	}{
+		{200, true},
+		{202, true},
+		{204, true},
+		{400, false},
+		{410, false},
+		{500, false},
+		{503, false},
+	} {
+		status.Store(int32(tt.status))
+		res := Send(context.Background(), localClient, srv.URL, "secret", d, time.Now())
+		if res.Err != nil || res.StatusCode != tt.status || res.OK() != tt.ok {
+			t.Errorf("status %d: Send() = %+v, OK() = %v", tt.status, res, res.OK())
+		}
+		if tt.ok != (res.Message() == "") {
+			t.Errorf("status %d: Message() = %q", tt.status, res.Message())
+		}
+	}
+
+	// A receiver that's gone.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	res := Send(context.Background(), localClient, closed.URL, "secret", d, time.Now())
+	if res.Err == nil || res.OK() || res.Message() == "" {
+		t.Errorf("closed receiver: Send() = %+v", res)
+	}
+
+	// A receiver that doesn't answer in time.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	res = Send(context.Background(), NewClient(50*time.Millisecond, true), slow.URL, "secret", d, time.Now())
+	if res.Err == nil || res.OK() {
+		t.Errorf("slow receiver: Send() = %+v", res)
+	}
+}
+
+func TestSendDoesntFollowRedirects(t *testing.T) {
+	followed := atomic.Bool{}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer srv.Close()
+
+	res := Send(context.Background(), localClient, srv.URL, "secret", Delivery{ID: "d1", Payload: []byte(`{}`)}, time.Now())
+	if res.OK() || res.StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("Send() = %+v", res)
+	}
+	if followed.Load() {
+		t.Error("redirect was followed")
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	hit := atomic.Bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit.Store(true)
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second, false)
+	d := Delivery{ID: "d1", Payload: []byte(`{}`)}
+	urls := []string{
+		srv.URL,
+		strings.Replace(srv.URL, "127.0.0.1", "localhost", 1),
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]:1/",
+		"http://0.0.0.0:1/",
+	}
+	for _, url := range urls {
+		res := Send(context.Background(), client, url, "secret", d, time.Now())
+		if !errors.Is(res.Err, ErrForbiddenAddress) {
+			t.Errorf("%s: Send() = %+v, want ErrForbiddenAddress", url, res)
+		}
+	}
+	if hit.Load() {
+		t.Error("receiver on loopback was reached")
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1":        false,
+		"127.8.9.10":       false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"0.1.2.3":          false,
+		"224.0.0.1":        false,
+		"255.255.255.255":  false,
+		"::":               false,
+		"::1":              false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"::ffff:127.0.0.1": false,
+		"::ffff:10.0.0.1":  false,
+		"64:ff9b::a00:1":   false,
+		"93.184.216.34":    true,
+		"1.1.1.1":          true,
+		"2606:4700::1111":  true,
+	} {
+		if got := Allowed(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Allowed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{
+		30 * time.Second,
+		time.Minute,
+		2 * time.Minute,
+		4 * time.Minute,
+		8 * time.Minute,
+		16 * time.Minute,
+		32 * time.Minute,
+		64 * time.Minute,
+		128 * time.Minute,
+		256 * time.Minute,
+		MaxDelay,
+		MaxDelay,
+	}
+	for i, w := range want {
+		if got := Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if got := Backoff(1000); got != MaxDelay {
+		t.Errorf("Backoff(1000) = %v, want %v", got, MaxDelay)
+	}
+}
+
+// clock is a time the tests move forward by hand.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type memDelivery struct {
+	job         Job
+	status      string
+	nextAttempt time.Time
+	log         []Result
+}
+
+type memEndpoint struct {
+	Endpoint
+	failures int
+}
+
+// memStore is the delivery queue kept in memory, with the same semantics
+// as the queries behind the database store.
+type memStore struct {
+	mu         sync.Mutex
+	clock      *clock
+	deliveries []*memDelivery
+	endpoints  map[uuid.UUID]*memEndpoint
+}
+
+func newMemStore(c *clock) *memStore {
+	return &memStore{clock: c, endpoints: map[uuid.UUID]*memEndpoint{}}
+}
+
+func (s *memStore) addEndpoint(url string) uuid.UUID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := uuid.New()
+	s.endpoints[id] = &memEndpoint{Endpoint: Endpoint{ID: id, URL: url, Secret: "secret"}}
+	return id
+}
+
+func (s *memStore) enqueue(endpointID uuid.UUID) *memDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := &memDelivery{job: Job{ID: uuid.New(), EndpointID: endpointID, Event: "chirp.created", Payload: []byte(`{}`)}, status: StatusPending, nextAttempt: s.clock.Now()}
+	s.deliveries = append(s.deliveries, d)
+	return d
+}
+
+func (s *memStore) delivery(d *memDelivery) memDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *d
+}
+
+func (s *memStore) endpoint(id uuid.UUID) memEndpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.endpoints[id]
+}
+
+func (s *memStore) ClaimDue(ctx context.Context, leaseUntil time.Time, max int) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := []Job{}
+	for _, d := range s.deliveries {
+		if len(jobs) == max {
+			break
+		}
+		if d.status == StatusPending && !d.nextAttempt.After(s.clock.Now()) {
+			d.nextAttempt = leaseUntil
+			jobs = append(jobs, d.job)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *memStore) Endpoint(ctx context.Context, id uuid.UUID) (Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.endpoints[id]
+	if !ok {
+		return Endpoint{}, errors.New("no such endpoint")
+	}
+	return e.Endpoint, nil
+}
+
+func (s *memStore) find(id uuid.UUID) *memDelivery {
+	for _, d := range s.deliveries {
+		if d.job.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *memStore) RecordAttempt(ctx context.Context, id uuid.UUID, res Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.find(id)
+	d.log = append(d.log, res)
+	return nil
+}
+
+func (s *memStore) Finish(ctx context.Context, id uuid.UUID, o Outcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.find(id)
+	d.status = o.Status
+	d.nextAttempt = o.NextAttempt
+	d.job.Attempts++
+	return nil
+}
+
+func (s *memStore) EndpointSucceeded(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endpoints[id].failures = 0
+	return nil
+}
+
+func (s *memStore) EndpointFailed(ctx context.Context, id uuid.UUID, disableAfter int) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.endpoints[id]
+	e.failures++
+	if e.Disabled || e.failures < disableAfter {
+		return e.failures, false, nil
+	}
+	e.Disabled = true
+	return e.failures, true, nil
+}
+
+// receiver is an httptest server answering every delivery with status,
+// once block is closed if there is one.
+type receiver struct {
+	*httptest.Server
+	status   atomic.Int32
+	requests atomic.Int32
+	block    chan struct{}
+}
+
+func newReceiver(t *testing.T, status int, block chan struct{}) *receiver {
+	r := &receiver{block: block}
+	r.status.Store(int32(status))
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.requests.Add(1)
+		if r.block != nil {
+			<-r.block
+		}
+		w.WriteHeader(int(r.status.Load()))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func newWorker(store *memStore, c *clock) *Worker {
+	return &Worker{Store: store, Client: localClient, Lease: 2 * time.Minute, Batch: 50, MaxAttempts: 10, DisableAfter: 25, Now: c.Now}
+}
+
+func TestWorkerDelivers(t *testing.T) {
+	c := &clock{now: time.Now()}
+	store := newMemStore(c)
+	recv := newReceiver(t, 200, nil)
+	endpoint := store.addEndpoint(recv.URL)
+	d := store.enqueue(endpoint)
+
+	n, err := newWorker(store, c).DeliverDue(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("DeliverDue() = %d, %v", n, err)
+	}
+	got := store.delivery(d)
+	if got.status != StatusDelivered || got.job.Attempts != 1 || len(got.log) != 1 || !got.log[0].OK() {
+		t.Errorf("delivery = %+v", got)
+	}
+	if recv.requests.Load() != 1 {
+		t.Errorf("receiver got %d requests", recv.requests.Load())
+	}
+}
+
+func TestWorkerLease(t *testing.T) {
+	c := &clock{now: time.Now()}
+	store := newMemStore(c)
+	recv := newReceiver(t, 200, make(chan struct{}))
+	endpoint := store.addEndpoint(recv.URL)
+	d := store.enqueue(endpoint)
+	w := newWorker(store, c)
+
+	// Two workers polling at once send the delivery only once.
+	var wg sync.WaitGroup
+	claimed := make([]int, 2)
+	for i := range claimed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed[i], _ = w.DeliverDue(context.Background())
+		}()
+	}
+	for recv.requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(recv.block)
+	wg.Wait()
+	if claimed[0]+claimed[1] != 1 || recv.requests.Load() != 1 {
+		t.Fatalf("claimed %v, receiver got %d requests", claimed, recv.requests.Load())
+	}
+	if store.delivery(d).status != StatusDelivered {
+		t.Fatalf("delivery = %+v", store.delivery(d))
+	}
+
+	// A delivery claimed by a worker that died is left alone until its
+	// lease runs out, and then sent.
+	d = store.enqueue(endpoint)
+	_, err := store.ClaimDue(context.Background(), c.Now().Add(w.Lease), w.Batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(w.Lease - time.Second)
+	if n, _ := w.DeliverDue(context.Background()); n != 0 {
+		t.Errorf("DeliverDue() claimed %d leased deliveries", n)
+	}
+	c.Advance(time.Second)
+	if n, _ := w.DeliverDue(context.Background()); n != 1 {
+		t.Errorf("DeliverDue() claimed %d deliveries after the lease ran out", n)
+	}
+	if store.delivery(d).status != StatusDelivered || recv.requests.Load() != 2 {
+		t.Errorf("delivery = %+v, receiver got %d requests", store.delivery(d), recv.requests.Load())
+	}
+}
+
+func TestWorkerRetriesThenFails(t *testing.T) {
+	c := &clock{now: time.Now()}
+	store := newMemStore(c)
+	recv := newReceiver(t, 500, nil)
+	endpoint := store.addEndpoint(recv.URL)
+	d := store.enqueue(endpoint)
+	w := newWorker(store, c)
+
+	for attempt := 1; attempt <= w.MaxAttempts; attempt++ {
+		n, err := w.DeliverDue(context.Background())
+		if err != nil || n != 1 {
+			t.Fatalf("attempt %d: DeliverDue() = %d, %v", attempt, n, err)
+		}
+		got := store.delivery(d)
+		if got.job.Attempts != attempt || len(got.log) != attempt || got.log[attempt-1].StatusCode != 500 {
+			t.Fatalf("attempt %d: delivery = %+v", attempt, got)
+		}
+		if attempt == w.MaxAttempts {
+			if got.status != StatusFailed {
+				t.Fatalf("after %d attempts status = %s, want failed", attempt, got.status)
+			}
+			break
+		}
+
+		if got.status != StatusPending || !got.nextAttempt.Equal(c.Now().Add(Backoff(attempt))) {
+			t.Fatalf("attempt %d: status %s, next attempt in %v, want pending in %v", attempt, got.status, got.nextAttempt.Sub(c.Now()), Backoff(attempt))
+		}
+		// Not retried before the backoff is up.
+		c.Advance(Backoff(attempt) - time.Second)
+		if n, _ := w.DeliverDue(context.Background()); n != 0 {
+			t.Fatalf("attempt %d: retried early", attempt)
+		}
+		c.Advance(time.Second)
+	}
+
+	c.Advance(MaxDelay)
+	if n, _ := w.DeliverDue(context.Background()); n != 0 {
+		t.Errorf("failed delivery claimed again")
+	}
+	if recv.requests.Load() != int32(w.MaxAttempts) {
+		t.Errorf("receiver got %d requests, want %d", recv.requests.Load(), w.MaxAttempts)
+	}
+	if e := store.endpoint(endpoint); e.Disabled || e.failures != w.MaxAttempts {
+		t.Errorf("endpoint = %+v", e)
+	}
+}
+
+func TestWorkerDisablesEndpoint(t *testing.T) {
+	c := &clock{now: time.Now()}
+	store := newMemStore(c)
+	recv := newReceiver(t, 503, nil)
+	endpoint := store.addEndpoint(recv.URL)
+	w := newWorker(store, c)
+
+	var disabled []int
+	var mu sync.Mutex
+	w.OnDisable = func(ctx context.Context, e Endpoint, failures int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.ID != endpoint {
+			t.Errorf("OnDisable called for %s", e.ID)
+		}
+		disabled = append(disabled, failures)
+	}
+
+	// One short of the limit, then a success ends the run.
+	for i := 0; i < w.DisableAfter-1; i++ {
+		store.enqueue(endpoint)
+	}
+	w.DeliverDue(context.Background())
+	if e := store.endpoint(endpoint); e.Disabled || e.failures != w.DisableAfter-1 {
+		t.Fatalf("endpoint = %+v", e)
+	}
+	recv.status.Store(200)
+	store.enqueue(endpoint)
+	c.Advance(MaxDelay)
+	w.DeliverDue(context.Background())
+	if e := store.endpoint(endpoint); e.Disabled || e.failures != 0 {
+		t.Fatalf("endpoint after a success = %+v", e)
+	}
+
+	// A run of DisableAfter failures disables it, once.
+	recv.status.Store(503)
+	for i := 0; i < w.DisableAfter+5; i++ {
+		store.enqueue(endpoint)
+	}
+	c.Advance(MaxDelay)
+	w.DeliverDue(context.Background())
+	if e := store.endpoint(endpoint); !e.Disabled {
+		t.Fatalf("endpoint not disabled after %d failures", e.failures)
+	}
+	if len(disabled) != 1 || disabled[0] != w.DisableAfter {
+		t.Errorf("OnDisable calls = %v, want [%d]", disabled, w.DisableAfter)
+	}
+
+	// Deliveries still queued for it are given up on without being sent.
+	requests := recv.requests.Load()
+	c.Advance(MaxDelay)
+	n, err := w.DeliverDue(context.Background())
+	if err != nil || n == 0 {
+		t.Fatalf("DeliverDue() = %d, %v", n, err)
+	}
+	if recv.requests.Load() != requests {
+		t.Errorf("disabled endpoint got %d more requests", recv.requests.Load()-requests)
+	}
+	for _, d := range store.deliveries {
+		if got := store.delivery(d); got.status == StatusPending {
+			t.Errorf("delivery %s still pending", got.job.ID)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	now := time.Now()
+	if o := Next(3, Result{StatusCode: 200}, 10, now); o.Status != StatusDelivered {
+		t.Errorf("Next(ok) = %+v", o)
+	}
+	if o := Next(3, Result{StatusCode: 500}, 10, now); o.Status != StatusPending || !o.NextAttempt.Equal(now.Add(Backoff(3))) {
+		t.Errorf("Next(3 failures) = %+v", o)
+	}
+	if o := Next(9, Result{Err: errors.New("refused")}, 10, now); o.Status != StatusPending {
+		t.Errorf("Next(9 failures) = %+v", o)
+	}
+	if o := Next(10, Result{StatusCode: 500}, 10, now); o.Status != StatusFailed {
+		t.Errorf("Next(10 failures) = %+v", o)
+	}
+	if o := Next(10, Result{StatusCode: 204}, 10, now); o.Status != StatusDelivered {
+		t.Errorf("Next(ok on the last attempt) = %+v", o)
+	}
+}
@@ -0,0 +1,162 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+var errEndpointDisabled = errors.New("endpoint disabled")
+
+type Endpoint struct {
+	ID       uuid.UUID
+	URL      string
+	Secret   string
+	Disabled bool
+}
+
+// Job is a delivery a worker has claimed. Attempts counts the attempts it
+// had before this one.
+type Job struct {
+	ID         uuid.UUID
+	EndpointID uuid.UUID
+	Event      string
+	Payload    []byte
+	Attempts   int
+}
+
+// Outcome is what becomes of a delivery after an attempt at it.
+type Outcome struct {
+	Status      string
+	NextAttempt time.Time
+	Result      Result
+}
+
+// Store keeps the delivery queue.
+type Store interface {
+	// ClaimDue claims up to max deliveries that are due, leasing them until
+	// leaseUntil so other workers leave them alone in the meantime. A
+	// delivery whose worker dies before finishing it is due again once its
+	// lease runs out.
+	ClaimDue(ctx context.Context, leaseUntil time.Time, max int) ([]Job, error)
+	Endpoint(ctx context.Context, id uuid.UUID) (Endpoint, error)
+	// RecordAttempt adds an attempt to a delivery's log.
+	RecordAttempt(ctx context.Context, id uuid.UUID, res Result) error
+	// Finish stores the outcome of an attempt and counts it against the
+	// delivery.
+	Finish(ctx context.Context, id uuid.UUID, o Outcome) error
+	// EndpointSucceeded ends an endpoint's run of failed attempts.
+	EndpointSucceeded(ctx context.Context, id uuid.UUID) error
+	// EndpointFailed counts a failed attempt against an endpoint and
+	// disables it once disableAfter have failed in a row. It returns the
+	// length of the run, and whether this call disabled the endpoint.
+	EndpointFailed(ctx context.Context, id uuid.UUID, disableAfter int) (int, bool, error)
+}
+
+type Worker struct {
+	Store  Store
+	Client *http.Client
+	Lease  time.Duration
+	Batch  int
+	// A delivery fails for good after MaxAttempts, and its endpoint is
+	// disabled after DisableAfter failed attempts in a row across all its
+	// deliveries.
+	MaxAttempts  int
+	DisableAfter int
+	// OnDisable, if set, is told about each endpoint the worker disables.
+	OnDisable func(ctx context.Context, endpoint Endpoint, failures int)
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+func (w *Worker) now() time.Time {
+	if w.Now == nil {
+		return time.Now()
+	}
+	return w.Now()
+}
+
+// Next decides what becomes of a delivery after its attempts-th attempt.
+// Failed attempts are retried after Backoff(attempts) until maxAttempts
+// have been made.
+func Next(attempts int, res Result, maxAttempts int, now time.Time) Outcome {
+	switch {
+	case res.OK():
+		return Outcome{Status: StatusDelivered, NextAttempt: now, Result: res}
+	case attempts >= maxAttempts:
+		return Outcome{Status: StatusFailed, NextAttempt: now, Result: res}
+	}
+	return Outcome{Status: StatusPending, NextAttempt: now.Add(Backoff(attempts)), Result: res}
+}
+
+// DeliverDue claims the deliveries that are due, sends them all at once and
+// waits for them to finish. It returns how many it claimed, along with any
+// errors from the store.
+func (w *Worker) DeliverDue(ctx context.Context) (int, error) {
+	jobs, err := w.Store.ClaimDue(ctx, w.now().Add(w.Lease), w.Batch)
+	if err != nil {
+		return 0, err
+	}
+
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = w.deliver(ctx, job)
+		}()
+	}
+	wg.Wait()
+	return len(jobs), errors.Join(errs...)
+}
+
+func (w *Worker) deliver(ctx context.Context, job Job) error {
+	endpoint, err := w.Store.Endpoint(ctx, job.EndpointID)
+	if err != nil {
+		return fmt.Errorf("delivery %s: %w", job.ID, err)
+	}
+
+	// Deliveries queued before their endpoint was disabled are given up on
+	// without being sent.
+	if endpoint.Disabled {
+		err = w.Store.Finish(ctx, job.ID, Outcome{Status: StatusFailed, NextAttempt: w.now(), Result: Result{Err: errEndpointDisabled}})
+		if err != nil {
+			return fmt.Errorf("delivery %s: %w", job.ID, err)
+		}
+		return nil
+	}
+
+	now := w.now()
+	res := Send(ctx, w.Client, endpoint.URL, endpoint.Secret, Delivery{ID: job.ID.String(), Event: job.Event, Payload: job.Payload}, now)
+	errs := []error{
+		w.Store.RecordAttempt(ctx, job.ID, res),
+		w.Store.Finish(ctx, job.ID, Next(job.Attempts+1, res, w.MaxAttempts, now)),
+	}
+	if res.OK() {
+		errs = append(errs, w.Store.EndpointSucceeded(ctx, endpoint.ID))
+	} else {
+		failures, disabled, err := w.Store.EndpointFailed(ctx, endpoint.ID, w.DisableAfter)
+		errs = append(errs, err)
+		if disabled && w.OnDisable != nil {
+			w.OnDisable(ctx, endpoint, failures)
+		}
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		return fmt.Errorf("delivery %s: %w", job.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+func (cfg *apiConfig) rotateKeys(rotation time.Duration) error {
+	err := cfg.keys.Reload()
+	if err != nil {
+		return err
+	}
+	if cfg.keys.ActiveAge() >= rotation {
+		err = cfg.keys.Rotate(cfg.keyAlg)
+		if err != nil {
+			return err
+		}
+		log.Printf("Rotated JWT signing key")
+	}
+	// Retired keys must outlive every access token they signed.
+	return cfg.keys.Prune(time.Duration(EXPIRES) * time.Second)
+}
+
+func (cfg *apiConfig) scheduleKeyRotation(rotation time.Duration) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := cfg.rotateKeys(rotation)
+		if err != nil {
+			log.Printf("Rotate JWT keys: %s", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) jwks(w http.ResponseWriter, r *http.Request) {
+	body, err := json.Marshal(cfg.keys.JWKS())
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(200)
+	w.Write(body)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -18,16 +20,37 @@ import (
 
 	"github.com/haneyeric/chirpy/internal/auth"
 	"github.com/haneyeric/chirpy/internal/database"
+	"github.com/haneyeric/chirpy/internal/entitlements"
+	"github.com/haneyeric/chirpy/internal/filter"
+	"github.com/haneyeric/chirpy/internal/spam"
+	"github.com/haneyeric/chirpy/internal/webhooks"
 )
 
 const EXPIRES = 60 * 60
+const MFA_EXPIRES = 5 * 60
 
 type apiConfig struct {
-	fileserverhits atomic.Int32
-	dbq            *database.Queries
-	platform       string
-	JWT_Secret     string
-	Polka_Key      string
+	fileserverhits     atomic.Int32
+	auditMu            sync.Mutex
+	wordFilter         atomic.Pointer[filter.Filter]
+	spamRules          spam.Rules
+	plans              entitlements.Plans
+	db                 *sql.DB
+	dbq                *database.Queries
+	platform           string
+	keys               *auth.KeySet
+	passwordPolicy     auth.PasswordPolicy
+	passwords          *auth.Passwords
+	deletionGrace      time.Duration
+	chirpRestoreWindow time.Duration
+	exportDir          string
+	keyAlg             string
+	Polka_Key          string
+	polkaSecrets       []string
+	polkaTolerance     time.Duration
+	webhookClient      *http.Client
+	RP_ID              string
+	RP_Origin          string
 }
 
 type Chirp struct {
@@ -49,10 +72,74 @@ type UserInput struct {
 
 func main() {
 	godotenv.Load()
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	dbURL := os.Getenv("DB_URL")
 	platform := os.Getenv("PLATFORM")
-	jwtsecret := os.Getenv("JWT_SECRET")
+	keydir := os.Getenv("JWT_KEY_DIR")
+	if keydir == "" {
+		keydir = "keys"
+	}
+	keyalg := os.Getenv("JWT_SIGNING_ALG")
+	if keyalg == "" {
+		keyalg = "ES256"
+	}
+	rotation, err := time.ParseDuration(os.Getenv("JWT_KEY_ROTATION"))
+	if err != nil {
+		rotation = 30 * 24 * time.Hour
+	}
 	polkakey := os.Getenv("POLKA_KEY")
+	polkasecrets := []string{}
+	for _, secret := range strings.Split(os.Getenv("POLKA_WEBHOOK_SECRETS"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			polkasecrets = append(polkasecrets, secret)
+		}
+	}
+	polkatolerance, err := time.ParseDuration(os.Getenv("POLKA_WEBHOOK_TOLERANCE"))
+	if err != nil {
+		polkatolerance = 5 * time.Minute
+	}
+	rpid := os.Getenv("WEBAUTHN_RP_ID")
+	if rpid == "" {
+		rpid = "localhost"
+	}
+	rporigin := os.Getenv("WEBAUTHN_ORIGIN")
+	if rporigin == "" {
+		rporigin = "http://localhost:8080"
+	}
+	passwords, err := loadPasswordHasher()
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy, err := loadPasswordPolicy(passwords)
+	if err != nil {
+		log.Fatal(err)
+	}
+	grace, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE"))
+	if err != nil {
+		grace = 30 * 24 * time.Hour
+	}
+	restorewindow, err := time.ParseDuration(os.Getenv("CHIRP_RESTORE_WINDOW"))
+	if err != nil {
+		restorewindow = 30 * 24 * time.Hour
+	}
+	spamrules, err := loadSpamRules()
+	if err != nil {
+		log.Fatal(err)
+	}
+	plans, err := loadPlans()
+	if err != nil {
+		log.Fatal(err)
+	}
+	exportdir := os.Getenv("EXPORT_DIR")
+	if exportdir == "" {
+		exportdir = "exports"
+	}
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		return
@@ -62,22 +149,105 @@ func main() {
 	const filerootpath = "."
 	mux := http.NewServeMux()
 
-	cfg := apiConfig{fileserverhits: atomic.Int32{}, dbq: dbQueries, platform: platform, JWT_Secret: jwtsecret, Polka_Key: polkakey}
+	cfg := apiConfig{fileserverhits: atomic.Int32{}, db: db, dbq: dbQueries, platform: platform, keyAlg: keyalg, passwordPolicy: policy, passwords: passwords, deletionGrace: grace, chirpRestoreWindow: restorewindow, exportDir: exportdir, spamRules: spamrules, plans: plans, Polka_Key: polkakey, polkaSecrets: polkasecrets, polkaTolerance: polkatolerance, webhookClient: webhooks.NewClient(WEBHOOK_TIMEOUT, platform == "dev"), RP_ID: rpid, RP_Origin: rporigin}
+
+	err = os.MkdirAll(keydir, 0700)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.keys, err = auth.LoadKeySet(keydir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = cfg.rotateKeys(rotation)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go cfg.scheduleKeyRotation(rotation)
+	go cfg.schedulePurgeAccounts()
+	go cfg.schedulePurgeChirps()
+	go cfg.scheduleExpireSubscriptions()
+	go cfg.scheduleWebhookDeliveries()
+	go cfg.schedulePurgeWebhookDeliveries()
+
+	err = os.MkdirAll(exportdir, 0700)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go cfg.schedulePurgeExports()
+
+	err = cfg.reloadFilter(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	go cfg.scheduleFilterReload()
 
 	mux.Handle("/app/", cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filerootpath)))))
 	mux.HandleFunc("GET /api/healthz", healthz)
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.jwks)
 	mux.HandleFunc("GET /api/chirps", cfg.getChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.getChirp)
-	mux.HandleFunc("POST /api/chirps", cfg.createChirp)
-	mux.HandleFunc("GET /admin/metrics", cfg.metrics)
-	mux.HandleFunc("POST /admin/reset", cfg.reset)
+	mux.HandleFunc("POST /api/chirps", cfg.middlewareAuth(cfg.createChirp, auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /admin/metrics", cfg.middlewareRole(cfg.metrics, auth.RoleAdmin))
+	mux.HandleFunc("POST /admin/reset", cfg.middlewareRole(cfg.reset, auth.RoleAdmin))
+	mux.HandleFunc("GET /admin/users", cfg.middlewareRole(cfg.adminSearchUsers, auth.RoleAdmin))
+	mux.HandleFunc("GET /admin/users/{userID}", cfg.middlewareRole(cfg.adminGetUser, auth.RoleAdmin))
+	mux.HandleFunc("DELETE /admin/users/{userID}", cfg.middlewareRole(cfg.adminDeleteUser, auth.RoleAdmin))
+	mux.HandleFunc("POST /admin/users/{userID}/suspension", cfg.middlewareRole(cfg.adminSuspendUser, auth.RoleAdmin))
+	mux.HandleFunc("DELETE /admin/users/{userID}/suspension", cfg.middlewareRole(cfg.adminUnsuspendUser, auth.RoleAdmin))
+	mux.HandleFunc("POST /admin/users/{userID}/logout", cfg.middlewareRole(cfg.adminLogoutUser, auth.RoleAdmin))
+	mux.HandleFunc("PUT /admin/users/{userID}/role", cfg.middlewareRole(cfg.adminSetUserRole, auth.RoleAdmin))
+	mux.HandleFunc("DELETE /admin/users/{userID}/chirpy-red", cfg.middlewareRole(cfg.adminResetChirpyRed, auth.RoleAdmin))
+	mux.HandleFunc("GET /admin/reports", cfg.middlewareRole(cfg.getReports, auth.RoleModerator))
+	mux.HandleFunc("POST /admin/reports/{chirpID}/decision", cfg.middlewareRole(cfg.decideReports, auth.RoleModerator))
+	mux.HandleFunc("GET /admin/chirps/{chirpID}", cfg.middlewareRole(cfg.getModerationChirp, auth.RoleModerator))
+	mux.HandleFunc("GET /admin/users/{userID}/chirps", cfg.middlewareRole(cfg.getModerationChirpsUser, auth.RoleModerator))
+	mux.HandleFunc("PUT /admin/users/{userID}/visibility", cfg.middlewareRole(cfg.setUserVisibility, auth.RoleModerator))
+	mux.HandleFunc("GET /admin/audit", cfg.middlewareRole(cfg.getAudit, auth.RoleAdmin))
+	mux.HandleFunc("GET /admin/audit/verify", cfg.middlewareRole(cfg.verifyAudit, auth.RoleAdmin))
+	mux.HandleFunc("GET /admin/webhooks", cfg.middlewareRole(cfg.getWebhookEvents, auth.RoleAdmin))
+	mux.HandleFunc("POST /admin/webhooks/{id}/replay", cfg.middlewareRole(cfg.replayWebhookEvent, auth.RoleAdmin))
+	mux.HandleFunc("GET /admin/filter/rules", cfg.middlewareRole(cfg.getFilterRules, auth.RoleAdmin))
+	mux.HandleFunc("PUT /admin/filter/rules", cfg.middlewareRole(cfg.putFilterRule, auth.RoleAdmin))
+	mux.HandleFunc("DELETE /admin/filter/rules/{ruleID}", cfg.middlewareRole(cfg.deleteFilterRule, auth.RoleAdmin))
 	mux.HandleFunc("POST /api/users", cfg.createUser)
 	mux.HandleFunc("POST /api/login", cfg.login)
+	mux.HandleFunc("POST /api/login/mfa", cfg.loginMFA)
+	mux.HandleFunc("POST /api/mfa/totp/enroll", cfg.middlewareAuth(cfg.enrollTOTP, auth.ScopeProfileWrite))
+	mux.HandleFunc("POST /api/mfa/totp/confirm", cfg.middlewareAuth(cfg.confirmTOTP, auth.ScopeProfileWrite))
+	mux.HandleFunc("POST /api/passkeys/register/begin", cfg.middlewareAuth(cfg.beginPasskeyRegistration, auth.ScopeProfileWrite))
+	mux.HandleFunc("POST /api/passkeys/register/finish", cfg.middlewareAuth(cfg.finishPasskeyRegistration, auth.ScopeProfileWrite))
+	mux.HandleFunc("POST /api/passkeys/login/begin", cfg.beginPasskeyLogin)
+	mux.HandleFunc("POST /api/passkeys/login/finish", cfg.finishPasskeyLogin)
 	mux.HandleFunc("POST /api/refresh", cfg.refresh)
 	mux.HandleFunc("POST /api/revoke", cfg.revoke)
-	mux.HandleFunc("PUT /api/users", cfg.updateUser)
-	mux.HandleFunc("POST /api/polka/webhooks", cfg.upgradeUser)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.deleteChirp)
+	mux.HandleFunc("PUT /api/users", cfg.middlewareAuth(cfg.updateUser, auth.ScopeProfileWrite))
+	mux.HandleFunc("DELETE /api/users/me", cfg.middlewareAuth(cfg.deleteAccount, auth.ScopeProfileWrite))
+	mux.HandleFunc("GET /api/users/me/entitlements", cfg.middlewareAuth(cfg.getEntitlements))
+	mux.HandleFunc("GET /api/users/me/subscription", cfg.middlewareAuth(cfg.getSubscription))
+	mux.HandleFunc("POST /api/users/me/export", cfg.middlewareAuth(cfg.startExport, auth.ScopeProfileWrite))
+	mux.HandleFunc("GET /api/users/me/export/{exportID}", cfg.middlewareAuth(cfg.getExport, auth.ScopeProfileWrite))
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.polkaWebhook)
+	mux.HandleFunc("POST /api/webhooks", cfg.middlewareAuth(cfg.createWebhookEndpoint, auth.ScopeWebhooksWrite))
+	mux.HandleFunc("GET /api/webhooks", cfg.middlewareAuth(cfg.getWebhookEndpoints, auth.ScopeWebhooksWrite))
+	mux.HandleFunc("DELETE /api/webhooks/{endpointID}", cfg.middlewareAuth(cfg.deleteWebhookEndpoint, auth.ScopeWebhooksWrite))
+	mux.HandleFunc("POST /api/webhooks/{endpointID}/enable", cfg.middlewareAuth(cfg.enableWebhookEndpoint, auth.ScopeWebhooksWrite))
+	mux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", cfg.middlewareAuth(cfg.getWebhookDeliveries, auth.ScopeWebhooksWrite))
+	mux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries/{deliveryID}", cfg.middlewareAuth(cfg.getWebhookDelivery, auth.ScopeWebhooksWrite))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.middlewareAuth(cfg.deleteChirp, auth.ScopeChirpsWrite))
+	mux.HandleFunc("PATCH /api/chirps/{chirpID}", cfg.middlewareAuth(cfg.editChirp, auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/history", cfg.getChirpHistory)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", cfg.middlewareAuth(cfg.restoreChirp, auth.ScopeChirpsWrite))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", cfg.middlewareAuth(cfg.reportChirp, auth.ScopeChirpsWrite))
+	mux.HandleFunc("POST /api/tokens", cfg.middlewareAuth(cfg.createAPIToken, auth.ScopeProfileWrite))
+	mux.HandleFunc("GET /api/tokens", cfg.middlewareAuth(cfg.getAPITokens, auth.ScopeProfileWrite))
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", cfg.middlewareAuth(cfg.revokeAPIToken, auth.ScopeProfileWrite))
+	mux.HandleFunc("POST /api/oauth/clients", cfg.middlewareAuth(cfg.createOAuthClient, auth.ScopeProfileWrite))
+	mux.HandleFunc("GET /api/oauth/authorizations", cfg.middlewareAuth(cfg.getOAuthAuthorizations, auth.ScopeProfileWrite))
+	mux.HandleFunc("DELETE /api/oauth/authorizations/{clientID}", cfg.middlewareAuth(cfg.revokeOAuthAuthorization, auth.ScopeProfileWrite))
+	mux.HandleFunc("GET /oauth/authorize", cfg.authorize)
+	mux.HandleFunc("POST /oauth/authorize", cfg.approveAuthorization)
+	mux.HandleFunc("POST /oauth/token", cfg.oauthToken)
 
 	server := &http.Server{
 		Addr:    ":" + port,
@@ -101,7 +271,7 @@ func (cfg *apiConfig) getChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirp, err := cfg.dbq.GetChirp(r.Context(), cid)
+	chirp, err := cfg.dbq.GetChirp(r.Context(), database.GetChirpParams{ID: cid, ViewerID: cfg.viewer(r)})
 
 	if err != nil {
 		w.WriteHeader(404)
@@ -131,12 +301,12 @@ func (cfg *apiConfig) getChirps(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(400)
 			return
 		}
-		chirps, err = cfg.dbq.GetChirpsUser(r.Context(), id)
+		chirps, err = cfg.dbq.GetChirpsUser(r.Context(), database.GetChirpsUserParams{UserID: id, ViewerID: cfg.viewer(r)})
 		if err != nil {
 			return
 		}
 	} else {
-		chirps, err = cfg.dbq.GetChirps(r.Context())
+		chirps, err = cfg.dbq.GetChirps(r.Context(), cfg.viewer(r))
 		if err != nil {
 			w.WriteHeader(400)
 			return
@@ -167,17 +337,19 @@ func (cfg *apiConfig) getChirps(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
-func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
-	type LoginResponse struct {
-		Id           uuid.UUID `json:"id,omitempty"`
-		Created_at   time.Time `json:"created_at,omitempty"`
-		Updated_at   time.Time `json:"updated_at,omitempty"`
-		Email        string    `json:"email,omitempty"`
-		Token        string    `json:"token,omitempty"`
-		RefreshToken string    `json:"refresh_token,omitempty"`
-		IsChirpyRed  bool      `json:"is_chirpy_red,omitempty"`
-	}
+type LoginResponse struct {
+	Id           uuid.UUID `json:"id,omitempty"`
+	Created_at   time.Time `json:"created_at,omitempty"`
+	Updated_at   time.Time `json:"updated_at,omitempty"`
+	Email        string    `json:"email,omitempty"`
+	Token        string    `json:"token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	IsChirpyRed  bool      `json:"is_chirpy_red,omitempty"`
+	MFARequired  bool      `json:"mfa_required,omitempty"`
+	MFAToken     string    `json:"mfa_token,omitempty"`
+}
 
+func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 
 	userInput := UserInput{}
@@ -186,25 +358,80 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		w.WriteHeader(401)
-		w.Write([]byte(("Incorrect email or password decode input")))
+		w.Write([]byte(("Incorrect email or password")))
+		return
+	}
+
+	ip := clientIP(r)
+	if wait := cfg.loginLockedFor(r.Context(), userInput.Email, ip); wait > 0 {
+		writeLocked(w, wait)
 		return
 	}
 
 	user, err := cfg.dbq.GetUser(r.Context(), userInput.Email)
 	if err != nil {
+		cfg.passwords.CheckDummyPassword(userInput.Password)
+		cfg.recordLoginFailure(r.Context(), userInput.Email, uuid.NullUUID{}, ip, "unknown email")
 		w.WriteHeader(401)
-		w.Write([]byte(("Incorrect email or password lookup")))
+		w.Write([]byte(("Incorrect email or password")))
 		return
 	}
-	err = auth.CheckPasswordHash(userInput.Password, user.HashedPassword)
+	err = cfg.passwords.CheckPasswordHash(userInput.Password, user.HashedPassword)
 
 	if err != nil {
+		cfg.recordLoginFailure(r.Context(), userInput.Email, uuid.NullUUID{UUID: user.ID, Valid: true}, ip, "bad password")
 		w.WriteHeader(401)
-		w.Write([]byte(("Incorrect email or password pass check")))
+		w.Write([]byte(("Incorrect email or password")))
+		return
+	}
+	cfg.rehashPassword(r.Context(), user, userInput.Password)
+
+	totp, err := cfg.dbq.GetTOTPSecret(r.Context(), user.ID)
+	if err == nil && totp.ConfirmedAt.Valid {
+		challenge, err := auth.MakeMFAChallenge(user.ID, cfg.keys, time.Duration(MFA_EXPIRES)*time.Second)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		body, err := json.Marshal(LoginResponse{MFARequired: true, MFAToken: challenge})
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(body)
+		return
+	}
+
+	cfg.clearLoginFailures(r.Context(), user.Email)
+	cfg.writeLogin(w, r, user)
+}
+
+func (cfg *apiConfig) writeLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	suspended, err := cfg.isSuspended(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if suspended {
+		w.WriteHeader(403)
+		w.Write([]byte("Account suspended"))
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.JWT_Secret, time.Duration(EXPIRES)*time.Second)
+	// Logging in again is how a user cancels a pending account deletion.
+	n, err := cfg.dbq.CancelAccountDeletion(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if n > 0 {
+		log.Printf("Cancelled pending deletion of user %s", user.ID)
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(user.ID), Action: "login", TargetType: "user", TargetID: user.ID.String(), IP: clientIP(r)})
+
+	token, err := auth.MakeJWT(user.ID, user.Role, auth.LoginScopes(user.Role), cfg.keys, time.Duration(EXPIRES)*time.Second)
 	if err != nil {
 		w.WriteHeader(401)
 		w.Write([]byte(fmt.Sprintf("Incorrect email or password token creation: %s", err)))
@@ -246,14 +473,25 @@ func (cfg *apiConfig) refresh(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(401)
 		return
 	}
-	if currToken.RevokedAt.Valid || currToken.ExpiresAt.Before(time.Now()) {
+	if currToken.RevokedAt.Valid || currToken.ExpiresAt.Before(time.Now()) || currToken.ClientID.Valid {
 		w.WriteHeader(401)
 		return
 	}
 
-	user := currToken.UserID
+	// The role is read fresh so a promotion or demotion takes effect at the
+	// next refresh rather than when the refresh token expires.
+	user, err := cfg.dbq.GetUserByID(r.Context(), currToken.UserID)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+	suspended, err := cfg.isSuspended(r.Context(), user.ID)
+	if err != nil || suspended {
+		w.WriteHeader(401)
+		return
+	}
 
-	newToken, err := auth.MakeJWT(user, cfg.JWT_Secret, time.Duration(EXPIRES)*time.Second)
+	newToken, err := auth.MakeJWT(user.ID, user.Role, auth.LoginScopes(user.Role), cfg.keys, time.Duration(EXPIRES)*time.Second)
 	if err != nil {
 		w.WriteHeader(401)
 		return
@@ -284,36 +522,44 @@ func (cfg *apiConfig) revoke(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(401)
 		return
 	}
+	if t, err := cfg.dbq.GetRefreshToken(r.Context(), token); err == nil {
+		cfg.audit(r.Context(), auditEvent{Actor: auditActor(t.UserID), Action: "refresh_token.revoke", TargetType: "user", TargetID: t.UserID.String(), IP: clientIP(r)})
+	}
 	w.WriteHeader(204)
 
 }
 
-func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-
-	if err != nil {
-		w.WriteHeader(401)
+func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
 		return
 	}
 
-	id, err := auth.ValidateJWT(token, cfg.JWT_Secret)
-	if err != nil {
-		w.WriteHeader(401)
-		return
-	}
+	id := principal.UserID
 
 	decoder := json.NewDecoder(r.Body)
 
 	userInput := UserInput{}
 
-	err = decoder.Decode(&userInput)
+	err := decoder.Decode(&userInput)
+
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	if v := cfg.passwordPolicy.Validate(userInput.Password, userInput.Email); v != nil {
+		writePasswordViolations(w, v)
+		return
+	}
 
+	hashed, err := cfg.passwords.HashedPassword(userInput.Password)
 	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
 
-	hashed, err := auth.HashedPassword(userInput.Password)
+	oldUser, err := cfg.dbq.GetUserByID(r.Context(), id)
 	if err != nil {
 		w.WriteHeader(500)
 		return
@@ -325,6 +571,17 @@ func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 		return
 	}
+
+	type updateDetails struct {
+		OldEmail        string `json:"old_email,omitempty"`
+		NewEmail        string `json:"new_email,omitempty"`
+		PasswordChanged bool   `json:"password_changed"`
+	}
+	details := updateDetails{PasswordChanged: true}
+	if oldUser.Email != newUser.Email {
+		details.OldEmail, details.NewEmail = oldUser.Email, newUser.Email
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(id), Action: "user.update", TargetType: "user", TargetID: id.String(), IP: clientIP(r), Details: details})
 	newUser.HashedPassword = ""
 	body, err := json.Marshal(newUser)
 
@@ -339,48 +596,21 @@ func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (cfg *apiConfig) upgradeUser(w http.ResponseWriter, r *http.Request) {
-	apikey, err := auth.GetApiKey(r.Header)
-
-	if err != nil || apikey != cfg.Polka_Key {
-		w.WriteHeader(401)
-		return
+func writePasswordViolations(w http.ResponseWriter, violations []auth.PasswordViolation) {
+	type violationResponse struct {
+		Error      string                   `json:"error"`
+		Violations []auth.PasswordViolation `json:"violations"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
-
-	type upgradeInput struct {
-		Event string `json:"event,omitempty"`
-		Data  struct {
-			UserID string `json:"user_id,omitempty"`
-		} `json:"data,omitempty"`
-	}
-
-	upgrade := upgradeInput{}
-
-	err = decoder.Decode(&upgrade)
-
+	body, err := json.Marshal(violationResponse{Error: "Password does not meet requirements", Violations: violations})
 	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
 
-	if upgrade.Event != "user.upgraded" {
-		w.WriteHeader(204)
-		return
-	}
-
-	id, err := uuid.Parse(upgrade.Data.UserID)
-	if err != nil {
-		w.WriteHeader(404)
-		return
-	}
-	_, err = cfg.dbq.UpgradeUser(r.Context(), id)
-	if err != nil {
-		w.WriteHeader(404)
-		return
-	}
-	w.WriteHeader(204)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+	w.Write(body)
 }
 
 func (cfg *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
@@ -395,7 +625,12 @@ func (cfg *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hashed, err := auth.HashedPassword(userInput.Password)
+	if v := cfg.passwordPolicy.Validate(userInput.Password, userInput.Email); v != nil {
+		writePasswordViolations(w, v)
+		return
+	}
+
+	hashed, err := cfg.passwords.HashedPassword(userInput.Password)
 	if err != nil {
 		w.WriteHeader(500)
 		return
@@ -420,66 +655,88 @@ func (cfg *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(201)
 	w.Write(body)
 }
-func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
 
-	if err != nil {
-		w.WriteHeader(401)
-		return
+// checkChirpBody applies the author's length limit and the word filter to a
+// new or edited chirp body, writing the error response itself when it's
+// refused.
+func (cfg *apiConfig) checkChirpBody(w http.ResponseWriter, body string, ent entitlements.Entitlements) (filter.Result, bool) {
+	if len(body) > ent.ChirpLength {
+		writeChirpError(w, "Chirp is too long")
+		return filter.Result{}, false
+	}
+
+	filtered := cfg.wordFilter.Load().Check(body)
+	if filtered.Rejected {
+		writeChirpError(w, "Chirp contains blocked language")
+		return filter.Result{}, false
 	}
+	return filtered, true
+}
+
+func writeChirpError(w http.ResponseWriter, msg string) {
+	b := ChirpResponse{Error: msg}
+	body, err := json.Marshal(b)
 
-	id, err := auth.ValidateJWT(token, cfg.JWT_Secret)
 	if err != nil {
-		w.WriteHeader(401)
+		w.WriteHeader(500)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	id := principal.UserID
+
 	decoder := json.NewDecoder(r.Body)
 	chirp := database.Chirp{}
-	err = decoder.Decode(&chirp)
+	err := decoder.Decode(&chirp)
 
 	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
 
-	if len(chirp.Body) > 140 {
-		b := ChirpResponse{Error: "Chirp is too long"}
-		body, err := json.Marshal(b)
-
-		if err != nil {
-			w.WriteHeader(500)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(400)
-		w.Write(body)
+	user, err := cfg.dbq.GetUserByID(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(401)
 		return
 	}
+	ent := cfg.userEntitlements(user)
 
-	words := strings.Split(chirp.Body, " ")
-	profanes := []string{"kerfuffle", "sharbert", "fornax"}
-
-	for i := 0; i < len(words); i++ {
-		for j := 0; j < len(profanes); j++ {
-			if strings.ToLower(words[i]) == profanes[j] {
-				words[i] = "****"
-				break
-			}
-		}
+	filtered, ok := cfg.checkChirpBody(w, chirp.Body, ent)
+	if !ok {
+		return
 	}
-	s := ChirpResponse{CleanedBody: strings.Join(words, " ")}
-	chirp.Body = s.CleanedBody
+	chirp.Body = filtered.Body
 
-	params := database.CreateChirpParams{Body: chirp.Body, UserID: id}
-	chirp, err = cfg.dbq.CreateChirp(r.Context(), params)
+	decision, err := cfg.scoreChirp(r.Context(), user, ent, chirp.Body)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
 
+	// The chirp and its spam decision are written together, so a chirp
+	// that should be held is never public in the meantime.
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		chirp, err = q.CreateChirp(r.Context(), database.CreateChirpParams{Body: chirp.Body, UserID: id})
+		if err != nil {
+			return err
+		}
+		return applySpamDecision(r.Context(), q, chirp, decision)
+	})
 	if err != nil {
-		fmt.Printf("Create chirp: %s", err)
-		w.WriteHeader(400)
+		log.Printf("Create chirp: %s", err)
+		w.WriteHeader(500)
 		return
 	}
+	if filtered.Flagged {
+		cfg.flagChirp(r.Context(), chirp, filtered)
+	}
+	cfg.emitWebhook(r.Context(), id, "chirp.created", chirp)
 
 	body, err := json.Marshal(chirp)
 
@@ -493,44 +750,159 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
-func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	id := principal.UserID
+
+	cid, err := uuid.Parse(r.PathValue("chirpID"))
 
 	if err != nil {
-		w.WriteHeader(401)
+		w.WriteHeader(404)
+		return
+	}
+	chrip, err := cfg.dbq.GetChirp(r.Context(), database.GetChirpParams{ID: cid, ViewerID: uuid.NullUUID{UUID: id, Valid: true}})
+	if err != nil {
+		w.WriteHeader(404)
 		return
 	}
 
-	id, err := auth.ValidateJWT(token, cfg.JWT_Secret)
+	if id != chrip.UserID {
+		w.WriteHeader(403)
+		return
+	}
+
+	err = cfg.dbq.SoftDeleteChirp(r.Context(), database.SoftDeleteChirpParams{ChirpID: cid, PurgeAfter: time.Now().Add(cfg.chirpRestoreWindow)})
 	if err != nil {
-		w.WriteHeader(401)
+		w.WriteHeader(500)
 		return
 	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(id), Action: "chirp.delete", TargetType: "chirp", TargetID: cid.String(), IP: clientIP(r)})
+	cfg.emitWebhook(r.Context(), id, "chirp.deleted", webhookChirpDeleted{ChirpID: cid, Reason: "author"})
+	w.WriteHeader(204)
+}
 
-	cid, err := uuid.Parse(r.PathValue("chirpID"))
+func (cfg *apiConfig) restoreChirp(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	id := principal.UserID
 
+	cid, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
 		w.WriteHeader(404)
 		return
 	}
-	chrip, err := cfg.dbq.GetChirp(r.Context(), cid)
-	if err != nil {
+	chirp, err := cfg.dbq.GetChirpIncludingHidden(r.Context(), cid)
+	if err != nil || chirp.UserID != id {
 		w.WriteHeader(404)
 		return
 	}
 
-	if id != chrip.UserID {
-		w.WriteHeader(403)
+	// Nothing to restore when the chirp isn't deleted, a moderator deleted
+	// it or its restore window has already run out.
+	n, err := cfg.dbq.RestoreChirp(r.Context(), cid)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if n == 0 {
+		w.WriteHeader(404)
 		return
 	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(id), Action: "chirp.restore", TargetType: "chirp", TargetID: cid.String(), IP: clientIP(r)})
 
-	err = cfg.dbq.DeleteChirp(r.Context(), cid)
+	body, err := json.Marshal(chirp)
 	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
-	w.WriteHeader(204)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) schedulePurgeChirps() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := cfg.dbq.PurgeDeletedChirps(context.Background())
+		if err != nil {
+			log.Printf("Purge deleted chirps: %s", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Purged %d deleted chirps", n)
+			cfg.audit(context.Background(), auditEvent{Action: "chirp.purge", Details: map[string]int64{"chirps": n}})
+		}
+	}
+}
+
+// inTx runs fn against a single transaction, which is committed if fn
+// succeeds and rolled back otherwise.
+func (cfg *apiConfig) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(cfg.dbq.WithTx(tx))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type authedHandler func(http.ResponseWriter, *http.Request, auth.Principal)
+
+func (cfg *apiConfig) middlewareAuth(next authedHandler, scopes ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			w.WriteHeader(401)
+			return
+		}
+
+		principal, err := cfg.authenticate(r.Context(), token)
+		if err != nil {
+			w.WriteHeader(401)
+			return
+		}
+
+		if !principal.HasScopes(scopes...) {
+			w.WriteHeader(403)
+			return
+		}
+
+		next(w, r, principal)
+	}
 }
+
+// middlewareRole guards staff routes, which need both the admin scope and
+// the role. Only first-party access tokens carry a role; API tokens and
+// OAuth client tokens always act as plain users.
+func (cfg *apiConfig) middlewareRole(next authedHandler, role string) http.HandlerFunc {
+	return cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+		if !principal.HasRole(role) {
+			w.WriteHeader(403)
+			return
+		}
+		next(w, r, principal)
+	}, auth.ScopeAdmin)
+}
+
+// viewer identifies the caller on routes that don't require a login, so
+// authors still see their own chirps wherever others can't. A missing or
+// invalid token just means an anonymous viewer.
+func (cfg *apiConfig) viewer(r *http.Request) uuid.NullUUID {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	principal, err := cfg.authenticate(r.Context(), token)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: principal.UserID, Valid: true}
+}
+
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cfg.fileserverhits.Add(1)
@@ -538,7 +910,7 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
-func (cfg *apiConfig) metrics(w http.ResponseWriter, r *http.Request) {
+func (cfg *apiConfig) metrics(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
 	w.Header().Add("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(fmt.Sprintf(`
@@ -550,14 +922,24 @@ func (cfg *apiConfig) metrics(w http.ResponseWriter, r *http.Request) {
 	</html>
 	`, cfg.fileserverhits.Load())))
 }
-func (cfg *apiConfig) reset(w http.ResponseWriter, r *http.Request) {
+func (cfg *apiConfig) reset(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	// Wiping the database stays limited to dev even for admins.
 	if cfg.platform != "dev" {
 		w.WriteHeader(403)
 		return
 	}
-	cfg.dbq.DeleteUsers(r.Context())
-	cfg.dbq.DeleteChirps(r.Context())
-	cfg.dbq.DeleteRefreshTokens(r.Context())
+	err := cfg.dbq.DeleteUsers(r.Context())
+	if err == nil {
+		err = cfg.dbq.DeleteChirps(r.Context())
+	}
+	if err == nil {
+		err = cfg.dbq.DeleteRefreshTokens(r.Context())
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "admin.reset", IP: clientIP(r)})
 	cfg.fileserverhits.Store(0)
 	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
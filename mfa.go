@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+const RECOVERY_CODES = 10
+
+func (cfg *apiConfig) enrollTOTP(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	id := principal.UserID
+
+	user, err := cfg.dbq.GetUserByID(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	existing, err := cfg.dbq.GetTOTPSecret(r.Context(), id)
+	if err == nil && existing.ConfirmedAt.Valid {
+		w.WriteHeader(409)
+		return
+	}
+
+	secret, err := auth.MakeTOTPSecret()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	_, err = cfg.dbq.UpsertTOTPSecret(r.Context(), database.UpsertTOTPSecretParams{UserID: id, Secret: secret})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	type enrollResponse struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}
+
+	body, err := json.Marshal(enrollResponse{Secret: secret, OtpauthURI: auth.TOTPURI("Chirpy", user.Email, secret)})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) confirmTOTP(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	id := principal.UserID
+
+	type confirmInput struct {
+		Code string `json:"code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := confirmInput{}
+	err := decoder.Decode(&input)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	totp, err := cfg.dbq.GetTOTPSecret(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+	if totp.ConfirmedAt.Valid {
+		w.WriteHeader(409)
+		return
+	}
+
+	step, err := auth.ValidateTOTP(input.Code, totp.Secret, time.Now())
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	codes, err := auth.MakeRecoveryCodes(RECOVERY_CODES)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	err = cfg.dbq.DeleteRecoveryCodes(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	for _, code := range codes {
+		err = cfg.dbq.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{CodeHash: auth.HashRecoveryCode(code), UserID: id})
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+	}
+
+	err = cfg.dbq.ConfirmTOTPSecret(r.Context(), database.ConfirmTOTPSecretParams{UserID: id, LastUsedStep: step})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(id), Action: "mfa.totp_enable", TargetType: "user", TargetID: id.String(), IP: clientIP(r)})
+
+	type confirmResponse struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	body, err := json.Marshal(confirmResponse{RecoveryCodes: codes})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) loginMFA(w http.ResponseWriter, r *http.Request) {
+	type mfaInput struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := mfaInput{}
+	err := decoder.Decode(&input)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	id, err := auth.ValidateMFAChallenge(input.MFAToken, cfg.keys)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	user, err := cfg.dbq.GetUserByID(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	ip := clientIP(r)
+	if wait := cfg.loginLockedFor(r.Context(), user.Email, ip); wait > 0 {
+		writeLocked(w, wait)
+		return
+	}
+
+	totp, err := cfg.dbq.GetTOTPSecret(r.Context(), id)
+	if err != nil || !totp.ConfirmedAt.Valid {
+		w.WriteHeader(401)
+		return
+	}
+
+	if len(input.RecoveryCode) > 0 {
+		n, err := cfg.dbq.UseRecoveryCode(r.Context(), database.UseRecoveryCodeParams{UserID: id, CodeHash: auth.HashRecoveryCode(input.RecoveryCode)})
+		if err != nil || n == 0 {
+			cfg.recordLoginFailure(r.Context(), user.Email, uuid.NullUUID{UUID: id, Valid: true}, ip, "bad recovery code")
+			w.WriteHeader(401)
+			return
+		}
+	} else {
+		step, err := auth.ValidateTOTP(input.Code, totp.Secret, time.Now())
+		if err != nil {
+			cfg.recordLoginFailure(r.Context(), user.Email, uuid.NullUUID{UUID: id, Valid: true}, ip, "bad totp code")
+			w.WriteHeader(401)
+			return
+		}
+		n, err := cfg.dbq.UseTOTPStep(r.Context(), database.UseTOTPStepParams{UserID: id, LastUsedStep: step})
+		if err != nil || n == 0 {
+			cfg.recordLoginFailure(r.Context(), user.Email, uuid.NullUUID{UUID: id, Valid: true}, ip, "reused totp code")
+			w.WriteHeader(401)
+			return
+		}
+	}
+
+	cfg.clearLoginFailures(r.Context(), user.Email)
+	cfg.writeLogin(w, r, user)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+const REPORT_DETAILS_MAX = 500
+const REPORT_QUEUE_LIMIT = 50
+const MODERATION_CHIRPS_LIMIT = 200
+
+var ReportCategories = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+
+var ModerationActions = []string{"dismiss", "hide", "delete", "warn", "suspend"}
+
+// VisibilityStates are the reach an account's chirps can have. Chirps from
+// limited and shadow-banned accounts are shown to nobody but their author,
+// in the feed, by author and by ID alike, so the author sees no difference.
+// The two states record how severe the moderator meant the restriction to
+// be.
+var VisibilityStates = []string{"normal", "limited", "shadow_banned"}
+
+func (cfg *apiConfig) reportChirp(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	cid, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	chirp, err := cfg.dbq.GetChirp(r.Context(), database.GetChirpParams{ID: cid, ViewerID: uuid.NullUUID{UUID: principal.UserID, Valid: true}})
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+	if chirp.UserID == principal.UserID {
+		w.WriteHeader(400)
+		return
+	}
+
+	type reportInput struct {
+		Category string `json:"category"`
+		Details  string `json:"details"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := reportInput{}
+	err = decoder.Decode(&input)
+	if err != nil || !slices.Contains(ReportCategories, input.Category) || utf8.RuneCountInString(input.Details) > REPORT_DETAILS_MAX {
+		w.WriteHeader(400)
+		return
+	}
+
+	report, err := cfg.dbq.CreateReport(r.Context(), database.CreateReportParams{ChirpID: chirp.ID, ReporterID: uuid.NullUUID{UUID: principal.UserID, Valid: true}, Category: input.Category, Details: input.Details})
+	if err != nil {
+		// Each user can report a chirp once.
+		w.WriteHeader(409)
+		return
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(body)
+}
+
+type ReportGroup struct {
+	ChirpID         uuid.UUID         `json:"chirp_id"`
+	Body            string            `json:"body"`
+	AuthorID        uuid.UUID         `json:"author_id"`
+	ReportCount     int64             `json:"report_count"`
+	Categories      []string          `json:"categories"`
+	FirstReportedAt time.Time         `json:"first_reported_at"`
+	Reports         []database.Report `json:"reports"`
+}
+
+func (cfg *apiConfig) getReports(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	groups, err := cfg.dbq.GetOpenReportGroups(r.Context(), REPORT_QUEUE_LIMIT)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []ReportGroup{}
+	for _, g := range groups {
+		reports, err := cfg.dbq.GetOpenReportsChirp(r.Context(), g.ChirpID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		res = append(res, ReportGroup{
+			ChirpID:         g.ChirpID,
+			Body:            g.Body,
+			AuthorID:        g.AuthorID,
+			ReportCount:     g.ReportCount,
+			Categories:      strings.Fields(g.Categories),
+			FirstReportedAt: g.FirstReportedAt,
+			Reports:         reports,
+		})
+	}
+
+	writeAdminJSON(w, 200, res)
+}
+
+func (cfg *apiConfig) decideReports(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	cid, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	type decisionInput struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := decisionInput{}
+	err = decoder.Decode(&input)
+	if err != nil || !slices.Contains(ModerationActions, input.Action) {
+		w.WriteHeader(400)
+		return
+	}
+
+	chirp, err := cfg.dbq.GetChirpIncludingHidden(r.Context(), cid)
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+	if input.Action == "suspend" && chirp.UserID == principal.UserID {
+		w.WriteHeader(400)
+		return
+	}
+	// Moderators can only suspend users below them.
+	if input.Action == "suspend" {
+		author, err := cfg.dbq.GetUserByID(r.Context(), chirp.UserID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if !principal.Outranks(author.Role) {
+			w.WriteHeader(403)
+			return
+		}
+	}
+
+	// The decision, its action and the reports it resolves are written
+	// together, so a failed action leaves the reports in the queue. They're
+	// resolved last.
+	var decision database.ModerationDecision
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		decision, err = q.CreateModerationDecision(r.Context(), database.CreateModerationDecisionParams{
+			ChirpID:     chirp.ID,
+			AuthorID:    uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+			ModeratorID: uuid.NullUUID{UUID: principal.UserID, Valid: true},
+			Action:      input.Action,
+			Note:        input.Note,
+		})
+		if err != nil {
+			return err
+		}
+		decisionID := uuid.NullUUID{UUID: decision.ID, Valid: true}
+
+		switch input.Action {
+		case "dismiss":
+			// Dismissing the reports on a chirp held by the spam checks
+			// publishes it.
+			err = q.ReleaseHeldChirp(r.Context(), chirp.ID)
+		case "hide":
+			err = q.HideChirp(r.Context(), database.HideChirpParams{ChirpID: chirp.ID, DecisionID: decisionID})
+		case "delete":
+			// Soft-deleted like an author's deletion, so the chirp stays
+			// visible to moderators until it's purged, but its author can't
+			// restore it.
+			err = q.SoftDeleteChirp(r.Context(), database.SoftDeleteChirpParams{ChirpID: chirp.ID, PurgeAfter: time.Now().Add(cfg.chirpRestoreWindow), DecisionID: decisionID})
+		case "warn":
+			_, err = q.CreateUserWarning(r.Context(), database.CreateUserWarningParams{UserID: chirp.UserID, DecisionID: decisionID, Reason: input.Note})
+		case "suspend":
+			_, err = q.SuspendUser(r.Context(), database.SuspendUserParams{
+				UserID:      chirp.UserID,
+				SuspendedBy: uuid.NullUUID{UUID: principal.UserID, Valid: true},
+				Reason:      input.Note,
+			})
+			if err == nil {
+				err = q.RevokeUserRefreshTokens(r.Context(), chirp.UserID)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = q.ResolveReports(r.Context(), database.ResolveReportsParams{ChirpID: chirp.ID, DecisionID: decisionID})
+		return err
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if input.Action == "delete" {
+		cfg.emitWebhook(r.Context(), chirp.UserID, "chirp.deleted", webhookChirpDeleted{ChirpID: chirp.ID, Reason: "moderation"})
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "moderation." + input.Action, TargetType: "chirp", TargetID: chirp.ID.String(), IP: clientIP(r), Details: map[string]string{"decision_id": decision.ID.String(), "author_id": chirp.UserID.String()}})
+
+	writeAdminJSON(w, 201, decision)
+}
+
+// ModerationChirp is a chirp as moderators see it: deleted and hidden chirps
+// included, with their state alongside.
+type ModerationChirp struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Body       string     `json:"body"`
+	UserID     uuid.UUID  `json:"user_id"`
+	EditedAt   *time.Time `json:"edited_at"`
+	Hidden     bool       `json:"hidden"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	PurgeAfter *time.Time `json:"purge_after,omitempty"`
+}
+
+func moderationChirp(c database.GetChirpModerationRow) ModerationChirp {
+	return ModerationChirp{ID: c.ID, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, Body: c.Body, UserID: c.UserID, EditedAt: c.EditedAt, Hidden: c.Hidden, DeletedAt: c.DeletedAt, PurgeAfter: c.PurgeAfter}
+}
+
+func (cfg *apiConfig) getModerationChirp(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	cid, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	chirp, err := cfg.dbq.GetChirpModeration(r.Context(), cid)
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	writeAdminJSON(w, 200, moderationChirp(chirp))
+}
+
+func (cfg *apiConfig) getModerationChirpsUser(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	chirps, err := cfg.dbq.GetChirpsUserModeration(r.Context(), database.GetChirpsUserModerationParams{UserID: user.ID, Limit: MODERATION_CHIRPS_LIMIT})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []ModerationChirp{}
+	for _, c := range chirps {
+		res = append(res, moderationChirp(database.GetChirpModerationRow(c)))
+	}
+	writeAdminJSON(w, 200, res)
+}
+
+func (cfg *apiConfig) setUserVisibility(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	type visibilityInput struct {
+		State  string `json:"state"`
+		Reason string `json:"reason"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := visibilityInput{}
+	err := decoder.Decode(&input)
+	if err != nil || !slices.Contains(VisibilityStates, input.State) {
+		w.WriteHeader(400)
+		return
+	}
+
+	if input.State == "normal" {
+		_, err = cfg.dbq.ClearUserVisibility(r.Context(), user.ID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "user.visibility", TargetType: "user", TargetID: user.ID.String(), IP: clientIP(r), Details: input})
+		w.WriteHeader(204)
+		return
+	}
+
+	visibility, err := cfg.dbq.SetUserVisibility(r.Context(), database.SetUserVisibilityParams{
+		UserID: user.ID,
+		State:  input.State,
+		SetBy:  uuid.NullUUID{UUID: principal.UserID, Valid: true},
+		Reason: input.Reason,
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "user.visibility", TargetType: "user", TargetID: user.ID.String(), IP: clientIP(r), Details: input})
+
+	writeAdminJSON(w, 200, visibility)
+}
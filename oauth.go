@@ -0,0 +1,471 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<html>
+  <body>
+    <h1>Authorize {{.Client}}</h1>
+    <p>{{.Client}} is asking to act on your Chirpy account with these permissions:</p>
+    <ul>
+      {{range .Scopes}}<li>{{.}}</li>{{end}}
+    </ul>
+    {{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+    <form method="POST" action="/oauth/authorize">
+      {{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{$v}}">
+      {{end}}
+      <p><label>Email <input type="email" name="email"></label></p>
+      <p><label>Password <input type="password" name="password"></label></p>
+      <p><label>Authenticator code (if enabled) <input type="text" name="totp_code" autocomplete="one-time-code"></label></p>
+      <button type="submit" name="decision" value="allow">Allow</button>
+      <button type="submit" name="decision" value="deny">Deny</button>
+    </form>
+  </body>
+</html>
+`))
+
+type authorizeRequest struct {
+	client      database.OauthClient
+	redirectURI string
+	scopes      []string
+	state       string
+	challenge   string
+}
+
+func (cfg *apiConfig) createOAuthClient(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	type clientInput struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Public       bool     `json:"public"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := clientInput{}
+	err := decoder.Decode(&input)
+	if err != nil || len(strings.TrimSpace(input.Name)) == 0 || len(input.RedirectURIs) == 0 {
+		w.WriteHeader(400)
+		return
+	}
+
+	for _, uri := range input.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" || strings.ContainsAny(uri, " \n") {
+			w.WriteHeader(400)
+			return
+		}
+		if u.Scheme != "https" && !(u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1")) {
+			w.WriteHeader(400)
+			return
+		}
+	}
+
+	id, err := auth.MakeOAuthClientID()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	secret := ""
+	secretHash := sql.NullString{}
+	if !input.Public {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	params := database.CreateOAuthClientParams{
+		ID:           id,
+		UserID:       principal.UserID,
+		Name:         strings.TrimSpace(input.Name),
+		SecretHash:   secretHash,
+		RedirectUris: strings.Join(input.RedirectURIs, " "),
+	}
+	client, err := cfg.dbq.CreateOAuthClient(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	type clientResponse struct {
+		ClientID     string   `json:"client_id"`
+		ClientSecret string   `json:"client_secret,omitempty"`
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+	}
+
+	body, err := json.Marshal(clientResponse{ClientID: client.ID, ClientSecret: secret, Name: client.Name, RedirectURIs: strings.Fields(client.RedirectUris)})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(body)
+}
+
+// parseAuthorizeRequest validates an authorization request. Errors about the
+// client or redirect URI must not be redirected, so those come back with a
+// nil redirect; anything else is reported to the client's redirect URI.
+func (cfg *apiConfig) parseAuthorizeRequest(r *http.Request, v url.Values) (authorizeRequest, *url.URL, string) {
+	client, err := cfg.dbq.GetOAuthClient(r.Context(), v.Get("client_id"))
+	if err != nil {
+		return authorizeRequest{}, nil, "unknown client"
+	}
+	redirectURI := v.Get("redirect_uri")
+	if !slices.Contains(strings.Fields(client.RedirectUris), redirectURI) {
+		return authorizeRequest{}, nil, "redirect_uri is not registered for this client"
+	}
+	redirect, err := url.Parse(redirectURI)
+	if err != nil {
+		return authorizeRequest{}, nil, "invalid redirect_uri"
+	}
+
+	req := authorizeRequest{client: client, redirectURI: redirectURI, state: v.Get("state"), challenge: v.Get("code_challenge")}
+	if v.Get("response_type") != "code" {
+		return req, redirect, "unsupported_response_type"
+	}
+	if v.Get("code_challenge_method") != "S256" || len(req.challenge) != 43 {
+		return req, redirect, "invalid_request"
+	}
+	req.scopes = strings.Fields(v.Get("scope"))
+	if len(req.scopes) == 0 {
+		return req, redirect, "invalid_scope"
+	}
+	for _, s := range req.scopes {
+		if !slices.Contains(auth.OAuthScopes, s) {
+			return req, redirect, "invalid_scope"
+		}
+	}
+	return req, redirect, ""
+}
+
+func redirectOAuth(w http.ResponseWriter, r *http.Request, redirect *url.URL, params url.Values) {
+	q := redirect.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	redirect.RawQuery = q.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (cfg *apiConfig) renderConsent(w http.ResponseWriter, status int, req authorizeRequest, msg string) {
+	params := map[string]string{
+		"response_type":         "code",
+		"client_id":             req.client.ID,
+		"redirect_uri":          req.redirectURI,
+		"scope":                 strings.Join(req.scopes, " "),
+		"state":                 req.state,
+		"code_challenge":        req.challenge,
+		"code_challenge_method": "S256",
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	consentTemplate.Execute(w, map[string]interface{}{"Client": req.client.Name, "Scopes": req.scopes, "Params": params, "Error": msg})
+}
+
+func (cfg *apiConfig) authorize(w http.ResponseWriter, r *http.Request) {
+	req, redirect, errCode := cfg.parseAuthorizeRequest(r, r.URL.Query())
+	if redirect == nil {
+		w.WriteHeader(400)
+		w.Write([]byte(errCode))
+		return
+	}
+	if errCode != "" {
+		redirectOAuth(w, r, redirect, url.Values{"error": {errCode}, "state": {req.state}})
+		return
+	}
+	cfg.renderConsent(w, 200, req, "")
+}
+
+func (cfg *apiConfig) approveAuthorization(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	req, redirect, errCode := cfg.parseAuthorizeRequest(r, r.PostForm)
+	if redirect == nil {
+		w.WriteHeader(400)
+		w.Write([]byte(errCode))
+		return
+	}
+	if errCode != "" {
+		redirectOAuth(w, r, redirect, url.Values{"error": {errCode}, "state": {req.state}})
+		return
+	}
+	if r.PostForm.Get("decision") != "allow" {
+		redirectOAuth(w, r, redirect, url.Values{"error": {"access_denied"}, "state": {req.state}})
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	ip := clientIP(r)
+	if wait := cfg.loginLockedFor(r.Context(), email, ip); wait > 0 {
+		setRetryAfter(w, wait)
+		cfg.renderConsent(w, 429, req, "Too many failed login attempts")
+		return
+	}
+
+	user, err := cfg.dbq.GetUser(r.Context(), email)
+	if err != nil {
+		cfg.passwords.CheckDummyPassword(r.PostForm.Get("password"))
+		cfg.recordLoginFailure(r.Context(), email, uuid.NullUUID{}, ip, "unknown email")
+		cfg.renderConsent(w, 401, req, "Incorrect email or password")
+		return
+	}
+	err = cfg.passwords.CheckPasswordHash(r.PostForm.Get("password"), user.HashedPassword)
+	if err != nil {
+		cfg.recordLoginFailure(r.Context(), email, uuid.NullUUID{UUID: user.ID, Valid: true}, ip, "bad password")
+		cfg.renderConsent(w, 401, req, "Incorrect email or password")
+		return
+	}
+	cfg.rehashPassword(r.Context(), user, r.PostForm.Get("password"))
+
+	totp, err := cfg.dbq.GetTOTPSecret(r.Context(), user.ID)
+	if err == nil && totp.ConfirmedAt.Valid {
+		step, err := auth.ValidateTOTP(r.PostForm.Get("totp_code"), totp.Secret, time.Now())
+		if err != nil {
+			cfg.recordLoginFailure(r.Context(), email, uuid.NullUUID{UUID: user.ID, Valid: true}, ip, "bad totp code")
+			cfg.renderConsent(w, 401, req, "Incorrect authenticator code")
+			return
+		}
+		n, err := cfg.dbq.UseTOTPStep(r.Context(), database.UseTOTPStepParams{UserID: user.ID, LastUsedStep: step})
+		if err != nil || n == 0 {
+			cfg.recordLoginFailure(r.Context(), email, uuid.NullUUID{UUID: user.ID, Valid: true}, ip, "reused totp code")
+			cfg.renderConsent(w, 401, req, "Incorrect authenticator code")
+			return
+		}
+	}
+	cfg.clearLoginFailures(r.Context(), email)
+
+	code, err := auth.MakeAuthorizationCode()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	scopes := strings.Join(req.scopes, " ")
+	err = cfg.dbq.UpsertOAuthGrant(r.Context(), database.UpsertOAuthGrantParams{UserID: user.ID, ClientID: req.client.ID, Scopes: scopes})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	params := database.CreateOAuthCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.redirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.challenge,
+	}
+	err = cfg.dbq.CreateOAuthCode(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	redirectOAuth(w, r, redirect, url.Values{"code": {code}, "state": {req.state}})
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	body, _ := json.Marshal(map[string]string{"error": code})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) oauthToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, 400, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	client, err := cfg.dbq.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		writeOAuthError(w, 401, "invalid_client")
+		return
+	}
+	if client.SecretHash.Valid && !auth.CheckSecretHash(clientSecret, client.SecretHash.String) {
+		writeOAuthError(w, 401, "invalid_client")
+		return
+	}
+
+	var userID uuid.UUID
+	var scopes string
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, err := cfg.dbq.ConsumeOAuthCode(r.Context(), auth.HashToken(r.PostForm.Get("code")))
+		if err != nil || code.ClientID != client.ID || code.RedirectUri != r.PostForm.Get("redirect_uri") {
+			writeOAuthError(w, 400, "invalid_grant")
+			return
+		}
+		if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+			writeOAuthError(w, 400, "invalid_grant")
+			return
+		}
+		userID = code.UserID
+		scopes = code.Scopes
+	case "refresh_token":
+		old, err := cfg.dbq.GetRefreshToken(r.Context(), r.PostForm.Get("refresh_token"))
+		if err != nil || old.ClientID.String != client.ID || old.RevokedAt.Valid || old.ExpiresAt.Before(time.Now()) {
+			writeOAuthError(w, 400, "invalid_grant")
+			return
+		}
+		err = cfg.dbq.RevokeRefreshToken(r.Context(), old.Token)
+		if err != nil {
+			writeOAuthError(w, 500, "server_error")
+			return
+		}
+		userID = old.UserID
+		scopes = old.Scopes
+	default:
+		writeOAuthError(w, 400, "unsupported_grant_type")
+		return
+	}
+
+	_, err = cfg.dbq.GetOAuthGrant(r.Context(), database.GetOAuthGrantParams{UserID: userID, ClientID: client.ID})
+	if err != nil {
+		writeOAuthError(w, 400, "invalid_grant")
+		return
+	}
+	suspended, err := cfg.isSuspended(r.Context(), userID)
+	if err != nil || suspended {
+		writeOAuthError(w, 400, "invalid_grant")
+		return
+	}
+
+	access, err := auth.MakeClientJWT(userID, client.ID, strings.Fields(scopes), cfg.keys, time.Duration(EXPIRES)*time.Second)
+	if err != nil {
+		writeOAuthError(w, 500, "server_error")
+		return
+	}
+	refresh, err := auth.MakeRefreshToken()
+	if err != nil {
+		writeOAuthError(w, 500, "server_error")
+		return
+	}
+	_, err = cfg.dbq.CreateOAuthRefreshToken(r.Context(), database.CreateOAuthRefreshTokenParams{
+		Token:    refresh,
+		UserID:   userID,
+		ClientID: sql.NullString{String: client.ID, Valid: true},
+		Scopes:   scopes,
+	})
+	if err != nil {
+		writeOAuthError(w, 500, "server_error")
+		return
+	}
+
+	type tokenResponse struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+
+	body, err := json.Marshal(tokenResponse{AccessToken: access, TokenType: "Bearer", ExpiresIn: EXPIRES, RefreshToken: refresh, Scope: scopes})
+	if err != nil {
+		writeOAuthError(w, 500, "server_error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) getOAuthAuthorizations(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	grants, err := cfg.dbq.GetOAuthGrantsUser(r.Context(), principal.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	type authorizationResponse struct {
+		ClientID  string    `json:"client_id"`
+		Name      string    `json:"name"`
+		Scopes    []string  `json:"scopes"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	res := []authorizationResponse{}
+	for _, g := range grants {
+		res = append(res, authorizationResponse{ClientID: g.ClientID, Name: g.Name, Scopes: strings.Fields(g.Scopes), CreatedAt: g.CreatedAt, UpdatedAt: g.UpdatedAt})
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) revokeOAuthAuthorization(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	clientID := r.PathValue("clientID")
+	n, err := cfg.dbq.DeleteOAuthGrant(r.Context(), database.DeleteOAuthGrantParams{UserID: principal.UserID, ClientID: clientID})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if n == 0 {
+		w.WriteHeader(404)
+		return
+	}
+
+	err = cfg.dbq.RevokeOAuthRefreshTokens(r.Context(), database.RevokeOAuthRefreshTokensParams{UserID: principal.UserID, ClientID: sql.NullString{String: clientID, Valid: true}})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "oauth.revoke", TargetType: "oauth_client", TargetID: clientID, IP: clientIP(r)})
+	w.WriteHeader(204)
+}
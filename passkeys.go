@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+type passkeyCredentialInput struct {
+	ID       string `json:"id"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+	} `json:"response"`
+}
+
+type passkeyDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+func (cfg *apiConfig) beginPasskeyRegistration(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	id := principal.UserID
+
+	user, err := cfg.dbq.GetUserByID(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	challenge, err := auth.MakeWebAuthnChallenge()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	err = cfg.dbq.CreateWebAuthnChallenge(r.Context(), database.CreateWebAuthnChallengeParams{Challenge: challenge, UserID: uuid.NullUUID{UUID: id, Valid: true}, Ceremony: "webauthn.create"})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	existing, err := cfg.dbq.GetPasskeysUser(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	exclude := []passkeyDescriptor{}
+	for _, p := range existing {
+		exclude = append(exclude, passkeyDescriptor{Type: "public-key", ID: p.ID})
+	}
+
+	type rpEntity struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	type userEntity struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	}
+	type pubKeyParam struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	}
+	type authenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	}
+	type registrationOptions struct {
+		Challenge              string                 `json:"challenge"`
+		RP                     rpEntity               `json:"rp"`
+		User                   userEntity             `json:"user"`
+		PubKeyCredParams       []pubKeyParam          `json:"pubKeyCredParams"`
+		Timeout                int                    `json:"timeout"`
+		Attestation            string                 `json:"attestation"`
+		ExcludeCredentials     []passkeyDescriptor    `json:"excludeCredentials"`
+		AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	}
+
+	opts := registrationOptions{
+		Challenge:              challenge,
+		RP:                     rpEntity{ID: cfg.RP_ID, Name: "Chirpy"},
+		User:                   userEntity{ID: base64.RawURLEncoding.EncodeToString(user.ID[:]), Name: user.Email, DisplayName: user.Email},
+		PubKeyCredParams:       []pubKeyParam{{Type: "public-key", Alg: -7}, {Type: "public-key", Alg: -8}},
+		Timeout:                300000,
+		Attestation:            "none",
+		ExcludeCredentials:     exclude,
+		AuthenticatorSelection: authenticatorSelection{ResidentKey: "required", UserVerification: "required"},
+	}
+
+	body, err := json.Marshal(opts)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) finishPasskeyRegistration(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	id := principal.UserID
+
+	decoder := json.NewDecoder(r.Body)
+	input := passkeyCredentialInput{}
+	err := decoder.Decode(&input)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	clientDataJSON, err := auth.DecodeBase64URL(input.Response.ClientDataJSON)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	attestationObject, err := auth.DecodeBase64URL(input.Response.AttestationObject)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	challenge, err := auth.ParseClientData(clientDataJSON, "webauthn.create", cfg.RP_Origin)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	pending, err := cfg.dbq.ConsumeWebAuthnChallenge(r.Context(), database.ConsumeWebAuthnChallengeParams{Challenge: challenge, Ceremony: "webauthn.create"})
+	if err != nil || pending.UserID.UUID != id {
+		w.WriteHeader(400)
+		return
+	}
+
+	cred, err := auth.VerifyRegistration(attestationObject, cfg.RP_ID)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	passkey, err := cfg.dbq.CreatePasskey(r.Context(), database.CreatePasskeyParams{
+		ID:        base64.RawURLEncoding.EncodeToString(cred.ID),
+		UserID:    id,
+		PublicKey: cred.PublicKey,
+		SignCount: int64(cred.SignCount),
+	})
+	if err != nil {
+		w.WriteHeader(409)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(id), Action: "passkey.register", TargetType: "passkey", TargetID: passkey.ID, IP: clientIP(r)})
+
+	passkey.PublicKey = nil
+	body, err := json.Marshal(passkey)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) beginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	challenge, err := auth.MakeWebAuthnChallenge()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	err = cfg.dbq.CreateWebAuthnChallenge(r.Context(), database.CreateWebAuthnChallengeParams{Challenge: challenge, Ceremony: "webauthn.get"})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	type assertionOptions struct {
+		Challenge        string `json:"challenge"`
+		RPID             string `json:"rpId"`
+		Timeout          int    `json:"timeout"`
+		UserVerification string `json:"userVerification"`
+	}
+
+	body, err := json.Marshal(assertionOptions{Challenge: challenge, RPID: cfg.RP_ID, Timeout: 300000, UserVerification: "required"})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) finishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	input := passkeyCredentialInput{}
+	err := decoder.Decode(&input)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	clientDataJSON, err := auth.DecodeBase64URL(input.Response.ClientDataJSON)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	authData, err := auth.DecodeBase64URL(input.Response.AuthenticatorData)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	signature, err := auth.DecodeBase64URL(input.Response.Signature)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	challenge, err := auth.ParseClientData(clientDataJSON, "webauthn.get", cfg.RP_Origin)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	_, err = cfg.dbq.ConsumeWebAuthnChallenge(r.Context(), database.ConsumeWebAuthnChallengeParams{Challenge: challenge, Ceremony: "webauthn.get"})
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	passkey, err := cfg.dbq.GetPasskey(r.Context(), input.ID)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	count, err := auth.VerifyAssertion(passkey.PublicKey, authData, clientDataJSON, signature, cfg.RP_ID)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	// Authenticators that don't keep a counter always report zero; otherwise
+	// a counter that fails to advance suggests a cloned credential.
+	if (count != 0 || passkey.SignCount != 0) && int64(count) <= passkey.SignCount {
+		w.WriteHeader(401)
+		return
+	}
+
+	err = cfg.dbq.UpdatePasskeySignCount(r.Context(), database.UpdatePasskeySignCountParams{ID: passkey.ID, SignCount: int64(count)})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	user, err := cfg.dbq.GetUserByID(r.Context(), passkey.UserID)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	cfg.writeLogin(w, r, user)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+func envInt(name string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// loadPasswordHasher builds the password hasher from PASSWORD_HASH
+// ("argon2id" or "bcrypt") and its parameters. Both algorithms can always
+// verify existing hashes.
+func loadPasswordHasher() (*auth.Passwords, error) {
+	bc := auth.BcryptHasher{Cost: envInt("BCRYPT_COST", 10)}
+	a2 := auth.Argon2idHasher{
+		Time:    uint32(envInt("ARGON2_TIME", 3)),
+		Memory:  uint32(envInt("ARGON2_MEMORY_KIB", 64*1024)),
+		Threads: uint8(envInt("ARGON2_THREADS", 4)),
+		KeyLen:  32,
+		SaltLen: 16,
+	}
+
+	switch os.Getenv("PASSWORD_HASH") {
+	case "", "argon2id":
+		return auth.NewPasswords(a2, bc)
+	case "bcrypt":
+		return auth.NewPasswords(bc, a2)
+	}
+	return nil, errors.New("PASSWORD_HASH must be argon2id or bcrypt")
+}
+
+// loadPasswordPolicy builds the rules new passwords must meet from
+// PASSWORD_MIN_LENGTH, PASSWORD_MIN_ENTROPY and BREACH_FILTER.
+func loadPasswordPolicy(passwords *auth.Passwords) (auth.PasswordPolicy, error) {
+	minentropy, err := strconv.ParseFloat(os.Getenv("PASSWORD_MIN_ENTROPY"), 64)
+	if err != nil {
+		minentropy = 35
+	}
+	policy := auth.PasswordPolicy{MinLength: envInt("PASSWORD_MIN_LENGTH", 8), MaxBytes: passwords.MaxBytes(), MinEntropy: minentropy}
+	if path := os.Getenv("BREACH_FILTER"); path != "" {
+		policy.Breached, err = auth.LoadBreachFilter(path)
+		if err != nil {
+			return auth.PasswordPolicy{}, err
+		}
+	}
+	return policy, nil
+}
+
+// rehashPassword upgrades a stored hash to the active algorithm and
+// parameters once the user has proven they know the password.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, user database.User, password string) {
+	if !cfg.passwords.NeedsRehash(user.HashedPassword) || len(password) > cfg.passwords.MaxBytes() {
+		return
+	}
+	hashed, err := cfg.passwords.HashedPassword(password)
+	if err != nil {
+		log.Printf("Rehash password: %s", err)
+		return
+	}
+	err = cfg.dbq.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{ID: user.ID, HashedPassword: hashed})
+	if err != nil {
+		log.Printf("Rehash password: %s", err)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+type ChirpHistoryResponse struct {
+	Chirp     database.Chirp           `json:"chirp"`
+	Revisions []database.ChirpRevision `json:"revisions"`
+}
+
+func (cfg *apiConfig) editChirp(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	id := principal.UserID
+
+	cid, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	type editInput struct {
+		Body string `json:"body"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := editInput{}
+	err = decoder.Decode(&input)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	chirp, err := cfg.dbq.GetChirp(r.Context(), database.GetChirpParams{ID: cid, ViewerID: uuid.NullUUID{UUID: id, Valid: true}})
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+	if chirp.UserID != id {
+		w.WriteHeader(403)
+		return
+	}
+
+	user, err := cfg.dbq.GetUserByID(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+	ent := cfg.userEntitlements(user)
+	if time.Since(chirp.CreatedAt) > time.Duration(ent.EditWindow) {
+		w.WriteHeader(403)
+		return
+	}
+
+	filtered, ok := cfg.checkChirpBody(w, input.Body, ent)
+	if !ok {
+		return
+	}
+
+	if filtered.Body != chirp.Body {
+		edited, err := cfg.dbq.EditChirp(r.Context(), database.EditChirpParams{
+			Body:              filtered.Body,
+			ID:                chirp.ID,
+			PreviousUpdatedAt: chirp.UpdatedAt,
+			PreviousBody:      chirp.Body,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// Someone else edited it first.
+			w.WriteHeader(409)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		chirp = database.Chirp(edited)
+
+		if filtered.Flagged {
+			cfg.flagChirp(r.Context(), chirp, filtered)
+		}
+		cfg.audit(r.Context(), auditEvent{Actor: auditActor(id), Action: "chirp.edit", TargetType: "chirp", TargetID: chirp.ID.String(), IP: clientIP(r)})
+	}
+
+	body, err := json.Marshal(chirp)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) getChirpHistory(w http.ResponseWriter, r *http.Request) {
+	cid, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	chirp, err := cfg.dbq.GetChirp(r.Context(), database.GetChirpParams{ID: cid, ViewerID: cfg.viewer(r)})
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	revisions, err := cfg.dbq.GetChirpRevisions(r.Context(), chirp.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if revisions == nil {
+		revisions = []database.ChirpRevision{}
+	}
+
+	body, err := json.Marshal(ChirpHistoryResponse{Chirp: chirp, Revisions: revisions})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
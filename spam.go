@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/database"
+	"github.com/haneyeric/chirpy/internal/entitlements"
+	"github.com/haneyeric/chirpy/internal/spam"
+)
+
+// At most this many of the author's recent chirps are compared against a
+// new one.
+const SPAM_RECENT_LIMIT = 200
+
+func loadSpamRules() (spam.Rules, error) {
+	path := os.Getenv("SPAM_RULES")
+	if path == "" {
+		return spam.DefaultRules, nil
+	}
+	return spam.LoadRules(path)
+}
+
+func (cfg *apiConfig) scoreChirp(ctx context.Context, user database.User, ent entitlements.Entitlements, body string) (spam.Decision, error) {
+	rules := cfg.spamRules
+	if ent.ChirpRate > 0 {
+		rules.RateLimit = ent.ChirpRate
+	}
+
+	now := time.Now()
+	window := max(time.Duration(rules.RateWindow), time.Duration(rules.DuplicateWindow))
+	rows, err := cfg.dbq.GetRecentChirpsUser(ctx, database.GetRecentChirpsUserParams{UserID: user.ID, CreatedAt: now.Add(-window), Limit: SPAM_RECENT_LIMIT})
+	if err != nil {
+		return spam.Decision{}, err
+	}
+	recent := []spam.Recent{}
+	for _, row := range rows {
+		recent = append(recent, spam.Recent{CreatedAt: row.CreatedAt, Body: row.Body})
+	}
+
+	return rules.Score(spam.Input{Body: body, AccountCreated: user.CreatedAt, Recent: recent, Now: now}), nil
+}
+
+// applySpamDecision records why a chirp was scored as it was and, for high
+// scores, holds it for review or keeps it out of the public feed. The author
+// is not told either way. It runs against q so it can share the chirp's
+// transaction.
+func applySpamDecision(ctx context.Context, q *database.Queries, chirp database.Chirp, d spam.Decision) error {
+	signals, err := json.Marshal(d.Signals)
+	if err != nil {
+		return err
+	}
+	err = q.CreateSpamDecision(ctx, database.CreateSpamDecisionParams{
+		ChirpID: chirp.ID,
+		UserID:  uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		Score:   d.Score,
+		Action:  string(d.Action),
+		Signals: signals,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch d.Action {
+	case spam.ActionLimit:
+		return q.LimitChirp(ctx, chirp.ID)
+	case spam.ActionHold:
+		err = q.HideChirp(ctx, database.HideChirpParams{ChirpID: chirp.ID})
+		if err != nil {
+			return err
+		}
+		names := []string{}
+		for _, s := range d.Signals {
+			names = append(names, s.Name)
+		}
+		_, err = q.CreateReport(ctx, database.CreateReportParams{ChirpID: chirp.ID, Category: "spam", Details: fmt.Sprintf("score %.1f: %s", d.Score, strings.Join(names, ", "))})
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+-- name: ScheduleAccountDeletion :one
+INSERT INTO account_deletions (user_id, requested_at, delete_after)
+VALUES (
+    $1, NOW(), $2
+)
+ON CONFLICT (user_id) DO UPDATE
+SET requested_at = NOW(), delete_after = EXCLUDED.delete_after
+RETURNING *;
+
+-- name: CancelAccountDeletion :execrows
+DELETE FROM account_deletions
+WHERE user_id = $1;
+
+-- name: PurgeDeletedAccounts :execrows
+DELETE FROM users
+WHERE id IN (
+    SELECT user_id FROM account_deletions
+    WHERE delete_after <= NOW()
+);
@@ -0,0 +1,26 @@
+-- name: GetLastAuditEntry :one
+SELECT * FROM audit_log
+ORDER BY seq DESC
+LIMIT 1;
+
+-- name: AppendAuditEntry :one
+INSERT INTO audit_log (created_at, actor_id, action, target_type, target_id, ip, details, prev_hash, hash)
+VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING *;
+
+-- name: GetAuditEntries :many
+SELECT * FROM audit_log
+WHERE (sqlc.narg(actor_id)::uuid IS NULL OR actor_id = sqlc.narg(actor_id))
+AND (sqlc.narg(target_id)::text IS NULL OR target_id = sqlc.narg(target_id))
+AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+AND seq < sqlc.arg(before_seq)
+ORDER BY seq DESC
+LIMIT sqlc.arg(max_entries);
+
+-- name: GetAuditEntriesAfter :many
+SELECT * FROM audit_log
+WHERE seq > $1
+ORDER BY seq
+LIMIT $2;
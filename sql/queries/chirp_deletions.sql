@@ -0,0 +1,35 @@
+-- name: SoftDeleteChirp :exec
+-- A moderator's deletion takes over one the author already made, so the
+-- author can no longer restore the chirp.
+UPDATE chirps
+SET deleted_at = COALESCE(deleted_at, NOW()),
+    purge_after = COALESCE(purge_after, sqlc.arg(purge_after)::timestamp),
+    deletion_decision_id = COALESCE(sqlc.narg(decision_id)::uuid, deletion_decision_id)
+WHERE id = sqlc.arg(chirp_id);
+
+-- name: RestoreChirp :execrows
+-- Chirps deleted by a moderator stay deleted.
+UPDATE chirps
+SET deleted_at = NULL, purge_after = NULL
+WHERE id = $1 AND deleted_at IS NOT NULL AND purge_after > NOW() AND deletion_decision_id IS NULL;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at IS NOT NULL AND purge_after <= NOW();
+
+-- name: GetChirpModeration :one
+-- Moderators see a chirp whatever state it is in, soft-deleted included.
+SELECT chirps.*, EXISTS (
+    SELECT 1 FROM hidden_chirps WHERE hidden_chirps.chirp_id = chirps.id
+) AS hidden
+FROM chirps
+WHERE chirps.id = $1;
+
+-- name: GetChirpsUserModeration :many
+SELECT chirps.*, EXISTS (
+    SELECT 1 FROM hidden_chirps WHERE hidden_chirps.chirp_id = chirps.id
+) AS hidden
+FROM chirps
+WHERE chirps.user_id = $1
+ORDER BY chirps.created_at DESC
+LIMIT $2;
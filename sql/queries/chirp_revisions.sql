@@ -0,0 +1,24 @@
+-- name: EditChirp :one
+-- The edit only applies if the chirp hasn't changed since previous_updated_at,
+-- and the body it replaces is kept as a revision in the same statement.
+WITH edited AS (
+    UPDATE chirps
+    SET body = sqlc.arg(body), updated_at = NOW(), edited_at = NOW()
+    WHERE id = sqlc.arg(id) AND updated_at = sqlc.arg(previous_updated_at)
+    RETURNING *
+), revision AS (
+    INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+    SELECT gen_random_uuid(), edited.id, sqlc.arg(previous_body), sqlc.arg(previous_updated_at), edited.updated_at
+    FROM edited
+)
+SELECT * FROM edited;
+
+-- name: GetChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY created_at;
+
+-- name: GetChirpRevisionsChirps :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, created_at;
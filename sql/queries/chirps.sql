@@ -6,17 +6,44 @@ VALUES (
 RETURNING *;
 
 -- name: GetChirps :many
+-- Limited chirps and the chirps of limited and shadow-banned authors are
+-- only shown to the author, here and in GetChirpsUser and GetChirp.
 SELECT * FROM chirps
+WHERE deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM account_deletions WHERE account_deletions.user_id = chirps.user_id
+) AND NOT EXISTS (
+    SELECT 1 FROM hidden_chirps WHERE hidden_chirps.chirp_id = chirps.id
+) AND (chirps.user_id = sqlc.narg(viewer_id) OR (NOT EXISTS (
+    SELECT 1 FROM limited_chirps WHERE limited_chirps.chirp_id = chirps.id
+) AND NOT EXISTS (
+    SELECT 1 FROM user_visibility WHERE user_visibility.user_id = chirps.user_id
+)))
 ORDER BY created_at;
 
 -- name: GetChirpsUser :many
 SELECT * FROM chirps
-WHERE user_id = $1
+WHERE user_id = sqlc.arg(user_id) AND deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM account_deletions WHERE account_deletions.user_id = chirps.user_id
+) AND NOT EXISTS (
+    SELECT 1 FROM hidden_chirps WHERE hidden_chirps.chirp_id = chirps.id
+) AND (chirps.user_id = sqlc.narg(viewer_id) OR (NOT EXISTS (
+    SELECT 1 FROM limited_chirps WHERE limited_chirps.chirp_id = chirps.id
+) AND NOT EXISTS (
+    SELECT 1 FROM user_visibility WHERE user_visibility.user_id = chirps.user_id
+)))
 ORDER BY created_at;
 
 -- name: GetChirp :one
 SELECT * FROM chirps
-WHERE id = $1
+WHERE id = sqlc.arg(id) AND deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM account_deletions WHERE account_deletions.user_id = chirps.user_id
+) AND NOT EXISTS (
+    SELECT 1 FROM hidden_chirps WHERE hidden_chirps.chirp_id = chirps.id
+) AND (chirps.user_id = sqlc.narg(viewer_id) OR (NOT EXISTS (
+    SELECT 1 FROM limited_chirps WHERE limited_chirps.chirp_id = chirps.id
+) AND NOT EXISTS (
+    SELECT 1 FROM user_visibility WHERE user_visibility.user_id = chirps.user_id
+)))
 ORDER BY created_at;
 
 -- name: DeleteChirp :exec
@@ -25,3 +52,15 @@ WHERE id = $1;
 
 -- name: DeleteChirps :exec
 DELETE FROM chirps;
+
+-- name: GetChirpsUserPage :many
+-- Pages through everything still held of a user's chirps, soft-deleted and
+-- held ones included.
+SELECT * FROM chirps
+WHERE user_id = $1 AND (created_at > $2 OR (created_at = $2 AND id > $3))
+ORDER BY created_at, id
+LIMIT $4;
+
+-- name: GetChirpIncludingHidden :one
+SELECT * FROM chirps
+WHERE id = $1;
@@ -0,0 +1,37 @@
+-- name: CreateDataExport :one
+-- Nothing is created while another of the user's exports is still pending.
+INSERT INTO data_exports (id, created_at, updated_at, user_id, status, expires_at)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, 'pending', NOW() + interval '7' day
+)
+ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING
+RETURNING *;
+
+-- name: FailStaleDataExports :exec
+-- Exports pending since before stale_before are taken to have died with the
+-- server that was running them.
+UPDATE data_exports
+SET updated_at = NOW(), status = 'failed'
+WHERE user_id = sqlc.arg(user_id) AND status = 'pending' AND created_at <= sqlc.arg(stale_before);
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = $1 AND user_id = $2;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET updated_at = NOW(), status = 'complete', size = $2
+WHERE id = $1;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET updated_at = NOW(), status = 'failed'
+WHERE id = $1;
+
+-- name: DeleteExpiredDataExports :many
+DELETE FROM data_exports
+WHERE expires_at <= NOW()
+RETURNING id;
+
+-- name: GetDataExportIDs :many
+SELECT id FROM data_exports;
@@ -0,0 +1,16 @@
+-- name: GetFilterRules :many
+SELECT * FROM filter_rules
+ORDER BY pattern;
+
+-- name: UpsertFilterRule :one
+INSERT INTO filter_rules (id, created_at, updated_at, pattern, action)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2
+)
+ON CONFLICT (pattern) DO UPDATE
+SET updated_at = NOW(), action = EXCLUDED.action
+RETURNING *;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules
+WHERE id = $1;
@@ -0,0 +1,31 @@
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE key = $1;
+
+-- name: RecordLoginThrottleFailure :one
+INSERT INTO login_throttles (key, updated_at, failures)
+VALUES (
+    $1, NOW(), 1
+)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.updated_at < NOW() - interval '15' minute THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: LockLoginThrottle :exec
+UPDATE login_throttles
+SET locked_until = $2
+WHERE key = $1;
+
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = $1;
+
+-- name: CreateLoginFailure :exec
+INSERT INTO login_failures (id, created_at, email, user_id, ip, reason)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4
+);
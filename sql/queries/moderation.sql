@@ -0,0 +1,54 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, chirp_id, reporter_id, category, details)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetOpenReportGroups :many
+SELECT chirps.id AS chirp_id, chirps.body, chirps.user_id AS author_id,
+    COUNT(reports.id) AS report_count,
+    string_agg(DISTINCT reports.category, ' ')::text AS categories,
+    MIN(reports.created_at)::timestamp AS first_reported_at
+FROM reports
+JOIN chirps ON chirps.id = reports.chirp_id
+WHERE reports.resolved_at IS NULL
+GROUP BY chirps.id
+ORDER BY report_count DESC, first_reported_at
+LIMIT $1;
+
+-- name: GetOpenReportsChirp :many
+SELECT * FROM reports
+WHERE chirp_id = $1 AND resolved_at IS NULL
+ORDER BY created_at;
+
+-- name: ResolveReports :execrows
+UPDATE reports
+SET resolved_at = NOW(), decision_id = $2
+WHERE chirp_id = $1 AND resolved_at IS NULL;
+
+-- name: CreateModerationDecision :one
+INSERT INTO moderation_decisions (id, created_at, chirp_id, author_id, moderator_id, action, note)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: HideChirp :exec
+INSERT INTO hidden_chirps (chirp_id, created_at, decision_id)
+VALUES (
+    $1, NOW(), $2
+)
+ON CONFLICT (chirp_id) DO UPDATE
+SET decision_id = COALESCE(EXCLUDED.decision_id, hidden_chirps.decision_id);
+
+-- name: CreateUserWarning :one
+INSERT INTO user_warnings (id, created_at, user_id, decision_id, reason)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3
+)
+RETURNING *;
+
+-- name: ReleaseHeldChirp :exec
+DELETE FROM hidden_chirps
+WHERE chirp_id = $1 AND decision_id IS NULL;
@@ -0,0 +1,44 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, user_id, name, secret_hash, redirect_uris)
+VALUES (
+    $1, NOW(), NOW(), $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: CreateOAuthCode :exec
+INSERT INTO oauth_codes (code_hash, created_at, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+VALUES (
+    $1, NOW(), $2, $3, $4, $5, $6, NOW() + interval '10' minute
+);
+
+-- name: ConsumeOAuthCode :one
+DELETE FROM oauth_codes
+WHERE code_hash = $1 AND expires_at > NOW()
+RETURNING *;
+
+-- name: UpsertOAuthGrant :exec
+INSERT INTO oauth_grants (user_id, client_id, created_at, updated_at, scopes)
+VALUES (
+    $1, $2, NOW(), NOW(), $3
+)
+ON CONFLICT (user_id, client_id) DO UPDATE
+SET updated_at = NOW(), scopes = EXCLUDED.scopes;
+
+-- name: GetOAuthGrant :one
+SELECT * FROM oauth_grants
+WHERE user_id = $1 AND client_id = $2;
+
+-- name: GetOAuthGrantsUser :many
+SELECT oauth_grants.client_id, oauth_clients.name, oauth_grants.scopes, oauth_grants.created_at, oauth_grants.updated_at
+FROM oauth_grants
+JOIN oauth_clients ON oauth_clients.id = oauth_grants.client_id
+WHERE oauth_grants.user_id = $1
+ORDER BY oauth_grants.created_at;
+
+-- name: DeleteOAuthGrant :execrows
+DELETE FROM oauth_grants
+WHERE user_id = $1 AND client_id = $2;
@@ -0,0 +1,31 @@
+-- name: CreatePasskey :one
+INSERT INTO passkeys (id, created_at, updated_at, user_id, public_key, sign_count)
+VALUES (
+    $1, NOW(), NOW(), $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetPasskey :one
+SELECT * FROM passkeys
+WHERE id = $1;
+
+-- name: GetPasskeysUser :many
+SELECT * FROM passkeys
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: UpdatePasskeySignCount :exec
+UPDATE passkeys
+SET updated_at = NOW(), last_used_at = NOW(), sign_count = $2
+WHERE id = $1;
+
+-- name: CreateWebAuthnChallenge :exec
+INSERT INTO webauthn_challenges (challenge, created_at, user_id, ceremony, expires_at)
+VALUES (
+    $1, NOW(), $2, $3, NOW() + interval '5' minute
+);
+
+-- name: ConsumeWebAuthnChallenge :one
+DELETE FROM webauthn_challenges
+WHERE challenge = $1 AND ceremony = $2 AND expires_at > NOW()
+RETURNING *;
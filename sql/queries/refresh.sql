@@ -16,3 +16,25 @@ WHERE token = $1;
 
 -- name: DeleteRefreshTokens :exec
 DELETE FROM refresh_tokens;
+
+-- name: CreateOAuthRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, client_id, scopes)
+VALUES (
+    $1, NOW(), NOW(), $2, NOW() + interval '60' day, $3, $4
+)
+RETURNING *;
+
+-- name: RevokeOAuthRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: GetRefreshTokensUser :many
+SELECT created_at, expires_at, revoked_at, client_id, scopes FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at;
@@ -0,0 +1,20 @@
+-- name: GetRecentChirpsUser :many
+-- Soft-deleted chirps still count, so deleting and reposting doesn't reset
+-- the rate and duplicate checks.
+SELECT created_at, body FROM chirps
+WHERE user_id = $1 AND created_at > $2
+ORDER BY created_at DESC
+LIMIT $3;
+
+-- name: CreateSpamDecision :exec
+INSERT INTO spam_decisions (id, created_at, chirp_id, user_id, score, action, signals)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, $5
+);
+
+-- name: LimitChirp :exec
+INSERT INTO limited_chirps (chirp_id, created_at)
+VALUES (
+    $1, NOW()
+)
+ON CONFLICT (chirp_id) DO NOTHING;
@@ -0,0 +1,34 @@
+-- name: UpsertSubscription :one
+-- A NULL period end leaves the current one in place. Events from before the
+-- one the subscription is already at change nothing and return no row.
+INSERT INTO subscriptions (user_id, status, current_period_end, created_at, updated_at, last_event_at)
+VALUES (
+    sqlc.arg(user_id), sqlc.arg(status), sqlc.narg(current_period_end), NOW(), NOW(), sqlc.arg(event_at)
+)
+ON CONFLICT (user_id) DO UPDATE
+SET status = EXCLUDED.status,
+    current_period_end = COALESCE(EXCLUDED.current_period_end, subscriptions.current_period_end),
+    updated_at = NOW(),
+    last_event_at = EXCLUDED.last_event_at
+WHERE subscriptions.last_event_at <= EXCLUDED.last_event_at
+RETURNING *;
+
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: GetLapsedSubscriptions :many
+SELECT * FROM subscriptions
+WHERE status IN ('active', 'past_due') AND current_period_end <= NOW();
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events (id, created_at, user_id, event, status, period_end)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4
+);
+
+-- name: GetSubscriptionEvents :many
+SELECT * FROM subscription_events
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
@@ -0,0 +1,37 @@
+-- name: UpsertTOTPSecret :one
+INSERT INTO totp_secrets (user_id, created_at, updated_at, secret)
+VALUES (
+    $1, NOW(), NOW(), $2
+)
+ON CONFLICT (user_id) DO UPDATE
+SET updated_at = NOW(), secret = EXCLUDED.secret, confirmed_at = NULL, last_used_step = 0
+RETURNING *;
+
+-- name: GetTOTPSecret :one
+SELECT * FROM totp_secrets
+WHERE user_id = $1;
+
+-- name: ConfirmTOTPSecret :exec
+UPDATE totp_secrets
+SET updated_at = NOW(), confirmed_at = NOW(), last_used_step = $2
+WHERE user_id = $1;
+
+-- name: UseTOTPStep :execrows
+UPDATE totp_secrets
+SET updated_at = NOW(), last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (code_hash, created_at, user_id)
+VALUES (
+    $1, NOW(), $2
+);
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
@@ -0,0 +1,16 @@
+-- name: SuspendUser :one
+INSERT INTO user_suspensions (user_id, created_at, suspended_by, reason)
+VALUES (
+    $1, NOW(), $2, $3
+)
+ON CONFLICT (user_id) DO UPDATE
+SET created_at = NOW(), suspended_by = EXCLUDED.suspended_by, reason = EXCLUDED.reason
+RETURNING *;
+
+-- name: GetUserSuspension :one
+SELECT * FROM user_suspensions
+WHERE user_id = $1;
+
+-- name: UnsuspendUser :execrows
+DELETE FROM user_suspensions
+WHERE user_id = $1;
@@ -0,0 +1,16 @@
+-- name: SetUserVisibility :one
+INSERT INTO user_visibility (user_id, updated_at, state, set_by, reason)
+VALUES (
+    $1, NOW(), $2, $3, $4
+)
+ON CONFLICT (user_id) DO UPDATE
+SET updated_at = NOW(), state = EXCLUDED.state, set_by = EXCLUDED.set_by, reason = EXCLUDED.reason
+RETURNING *;
+
+-- name: GetUserVisibility :one
+SELECT * FROM user_visibility
+WHERE user_id = $1;
+
+-- name: ClearUserVisibility :execrows
+DELETE FROM user_visibility
+WHERE user_id = $1;
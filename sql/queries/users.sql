@@ -23,3 +23,34 @@ WHERE email = $1;
 
 -- name: DeleteUsers :exec
 DELETE FROM users;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET updated_at = NOW(), hashed_password = $2
+WHERE id = $1;
+
+-- name: SetUserRole :one
+UPDATE users
+SET updated_at = NOW(), role = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: SearchUsers :many
+SELECT * FROM users
+WHERE email ILIKE $1
+ORDER BY email
+LIMIT $2;
+
+-- name: DowngradeUser :one
+UPDATE users
+SET updated_at = NOW(), is_chirpy_red = FALSE
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
@@ -0,0 +1,51 @@
+-- name: EnqueueWebhookDeliveries :execrows
+-- Queues the event for each of the user's enabled endpoints subscribed to it.
+INSERT INTO webhook_deliveries (id, created_at, endpoint_id, event_id, event_type, payload, status, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), id, sqlc.arg(event_id), sqlc.arg(event_type), sqlc.arg(payload), 'pending', NOW()
+FROM webhook_endpoints
+WHERE user_id = sqlc.arg(user_id) AND disabled_at IS NULL
+AND sqlc.arg(event_type) = ANY(string_to_array(event_types, ' '));
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Claimed deliveries are leased until lease_until, so other instances leave
+-- them alone, and a worker that dies mid-delivery only delays them.
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(max_deliveries)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_status_code = $4, last_error = $5, delivered_at = $6
+WHERE id = $1;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES (
+    gen_random_uuid(), $1, NOW(), $2, $3, $4
+);
+
+-- name: GetWebhookDeliveriesEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1 AND endpoint_id = $2;
+
+-- name: GetWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at;
+
+-- name: PurgeWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending' AND created_at < $1;
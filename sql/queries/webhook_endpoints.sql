@@ -0,0 +1,38 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, event_types)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: GetWebhookEndpointsUser :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnableWebhookEndpoint :execrows
+UPDATE webhook_endpoints
+SET disabled_at = NULL, consecutive_failures = 0, updated_at = NOW()
+WHERE id = $1 AND user_id = $2;
+
+-- name: RecordWebhookEndpointFailure :one
+-- Endpoints are disabled once disable_after attempts in a row have failed.
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    disabled_at = COALESCE(disabled_at, CASE WHEN consecutive_failures + 1 >= sqlc.arg(disable_after)::int THEN NOW() END),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ResetWebhookEndpointFailures :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0
+WHERE id = $1 AND consecutive_failures > 0;
@@ -0,0 +1,37 @@
+-- name: ClaimWebhookEvent :one
+-- Stores a new delivery, or takes back a redelivery of one that failed or
+-- has been pending since before stale_before. Returns no row for events
+-- already handled or being handled.
+INSERT INTO webhook_events (id, source, event, payload, status, attempts, received_at, claimed_at)
+VALUES (
+    sqlc.arg(id), sqlc.arg(source), sqlc.arg(event), sqlc.arg(payload), 'pending', 1, NOW(), NOW()
+)
+ON CONFLICT (id) DO UPDATE
+SET status = 'pending', attempts = webhook_events.attempts + 1, claimed_at = NOW()
+WHERE webhook_events.status = 'failed'
+OR (webhook_events.status = 'pending' AND webhook_events.claimed_at < sqlc.arg(stale_before))
+RETURNING *;
+
+-- name: RetryWebhookEvent :one
+-- Takes back an event that failed or has been pending since before
+-- stale_before, under the same lease as ClaimWebhookEvent.
+UPDATE webhook_events
+SET status = 'pending', attempts = attempts + 1, claimed_at = NOW()
+WHERE id = sqlc.arg(id) AND (status = 'failed' OR (status = 'pending' AND claimed_at < sqlc.arg(stale_before)))
+RETURNING *;
+
+-- name: FinishWebhookEvent :one
+UPDATE webhook_events
+SET status = $2, last_error = $3, processed_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: GetWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+ORDER BY received_at DESC
+LIMIT sqlc.arg(max_events);
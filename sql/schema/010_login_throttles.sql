@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE login_throttles(
+    key TEXT PRIMARY KEY,
+    updated_at TIMESTAMP NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP
+);
+
+CREATE TABLE login_failures(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    email TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    ip TEXT NOT NULL,
+    reason TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE login_failures;
+DROP TABLE login_throttles;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE account_deletions(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    requested_at TIMESTAMP NOT NULL,
+    delete_after TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE account_deletions;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE user_suspensions(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    suspended_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL DEFAULT ''
+);
+
+-- +goose Down
+DROP TABLE user_suspensions;
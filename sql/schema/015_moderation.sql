@@ -0,0 +1,45 @@
+-- +goose Up
+CREATE TABLE moderation_decisions(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL,
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL CHECK (action IN ('dismiss', 'hide', 'delete', 'warn', 'suspend')),
+    note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE reports(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    -- NULL for reports raised automatically rather than by a user.
+    reporter_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    category TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    decision_id UUID REFERENCES moderation_decisions(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    UNIQUE(chirp_id, reporter_id)
+);
+
+CREATE INDEX reports_open_idx ON reports(chirp_id) WHERE resolved_at IS NULL;
+
+CREATE TABLE hidden_chirps(
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    decision_id UUID REFERENCES moderation_decisions(id) ON DELETE SET NULL
+);
+
+CREATE TABLE user_warnings(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    decision_id UUID REFERENCES moderation_decisions(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL DEFAULT ''
+);
+
+-- +goose Down
+DROP TABLE user_warnings;
+DROP TABLE hidden_chirps;
+DROP TABLE reports;
+DROP TABLE moderation_decisions;
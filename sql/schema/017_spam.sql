@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE spam_decisions(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    score DOUBLE PRECISION NOT NULL,
+    action TEXT NOT NULL,
+    signals JSONB NOT NULL
+);
+
+CREATE TABLE limited_chirps(
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX chirps_user_created_idx ON chirps(user_id, created_at);
+
+-- +goose Down
+DROP INDEX chirps_user_created_idx;
+DROP TABLE limited_chirps;
+DROP TABLE spam_decisions;
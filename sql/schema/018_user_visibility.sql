@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE user_visibility(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    updated_at TIMESTAMP NOT NULL,
+    state TEXT NOT NULL CHECK (state IN ('limited', 'shadow_banned')),
+    set_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL DEFAULT ''
+);
+
+-- +goose Down
+DROP TABLE user_visibility;
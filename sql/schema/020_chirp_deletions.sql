@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE chirp_deletions(
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    deleted_at TIMESTAMP NOT NULL,
+    purge_after TIMESTAMP NOT NULL
+);
+
+CREATE INDEX chirp_deletions_purge_after_idx ON chirp_deletions(purge_after);
+
+-- +goose Down
+DROP TABLE chirp_deletions;
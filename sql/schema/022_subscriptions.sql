@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE subscriptions(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'canceled', 'refunded', 'expired')),
+    current_period_end TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscriptions_period_end_idx ON subscriptions(current_period_end);
+
+CREATE TABLE subscription_events(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    status TEXT NOT NULL,
+    period_end TIMESTAMP
+);
+
+CREATE INDEX subscription_events_user_idx ON subscription_events(user_id, created_at);
+
+-- Existing members have no known period end, so they don't lapse until
+-- Polka next tells us about them.
+INSERT INTO subscriptions (user_id, status, current_period_end, created_at, updated_at)
+SELECT id, 'active', NULL, NOW(), NOW() FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscription_events;
+DROP TABLE subscriptions;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE webhook_events(
+    id TEXT PRIMARY KEY,
+    source TEXT NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'processed', 'ignored', 'failed')),
+    attempts INTEGER NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    received_at TIMESTAMP NOT NULL,
+    processed_at TIMESTAMP
+);
+
+CREATE INDEX webhook_events_status_idx ON webhook_events(status, received_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chirp_deletions ADD COLUMN decision_id UUID REFERENCES moderation_decisions(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE chirp_deletions DROP COLUMN decision_id;
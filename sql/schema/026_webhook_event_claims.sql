@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE webhook_events ADD COLUMN claimed_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- +goose Down
+ALTER TABLE webhook_events DROP COLUMN claimed_at;
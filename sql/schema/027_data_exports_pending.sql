@@ -0,0 +1,15 @@
+-- +goose Up
+-- Only the newest of a user's pending exports survives, so the index can be
+-- built.
+UPDATE data_exports
+SET updated_at = NOW(), status = 'failed'
+WHERE status = 'pending' AND EXISTS (
+    SELECT 1 FROM data_exports newer
+    WHERE newer.user_id = data_exports.user_id AND newer.status = 'pending'
+    AND (newer.created_at, newer.id) > (data_exports.created_at, data_exports.id)
+);
+
+CREATE UNIQUE INDEX data_exports_pending_idx ON data_exports(user_id) WHERE status = 'pending';
+
+-- +goose Down
+DROP INDEX data_exports_pending_idx;
@@ -0,0 +1,40 @@
+-- +goose Up
+-- Soft deletion moves from its own table onto chirps, so reads filter on a
+-- column instead of another NOT EXISTS.
+ALTER TABLE chirps
+ADD COLUMN deleted_at TIMESTAMP,
+ADD COLUMN purge_after TIMESTAMP,
+ADD COLUMN deletion_decision_id UUID REFERENCES moderation_decisions(id) ON DELETE SET NULL,
+ADD CONSTRAINT chirps_deletion_check CHECK ((deleted_at IS NULL) = (purge_after IS NULL));
+
+UPDATE chirps
+SET deleted_at = chirp_deletions.deleted_at, purge_after = chirp_deletions.purge_after, deletion_decision_id = chirp_deletions.decision_id
+FROM chirp_deletions
+WHERE chirp_deletions.chirp_id = chirps.id;
+
+DROP TABLE chirp_deletions;
+
+CREATE INDEX chirps_live_created_idx ON chirps(created_at) WHERE deleted_at IS NULL;
+CREATE INDEX chirps_purge_after_idx ON chirps(purge_after) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+CREATE TABLE chirp_deletions(
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    deleted_at TIMESTAMP NOT NULL,
+    purge_after TIMESTAMP NOT NULL,
+    decision_id UUID REFERENCES moderation_decisions(id) ON DELETE SET NULL
+);
+
+CREATE INDEX chirp_deletions_purge_after_idx ON chirp_deletions(purge_after);
+
+INSERT INTO chirp_deletions (chirp_id, deleted_at, purge_after, decision_id)
+SELECT id, deleted_at, purge_after, deletion_decision_id FROM chirps
+WHERE deleted_at IS NOT NULL;
+
+DROP INDEX chirps_purge_after_idx;
+DROP INDEX chirps_live_created_idx;
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_deletion_check,
+DROP COLUMN deletion_decision_id,
+DROP COLUMN purge_after,
+DROP COLUMN deleted_at;
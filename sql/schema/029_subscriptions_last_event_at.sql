@@ -0,0 +1,9 @@
+-- +goose Up
+-- When the event behind a subscription's current status happened, so events
+-- that arrive or are replayed out of order don't undo newer ones.
+ALTER TABLE subscriptions ADD COLUMN last_event_at TIMESTAMP;
+UPDATE subscriptions SET last_event_at = updated_at;
+ALTER TABLE subscriptions ALTER COLUMN last_event_at SET NOT NULL;
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN last_event_at;
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+	"github.com/haneyeric/chirpy/internal/entitlements"
+)
+
+// Polka events that don't say when the paid period ends are taken to cover
+// this long.
+const SUBSCRIPTION_PERIOD = 30 * 24 * time.Hour
+const SUBSCRIPTION_HISTORY_LIMIT = 50
+
+// PolkaEvents maps each Polka webhook event to the subscription status it
+// leaves behind.
+var PolkaEvents = map[string]string{
+	"user.upgraded":       "active",
+	"user.renewed":        "active",
+	"user.payment_failed": "past_due",
+	"user.downgraded":     "canceled",
+	"user.refunded":       "refunded",
+}
+
+// hasRed reports whether a subscription status still carries Chirpy Red. A
+// failed payment keeps it until the period Polka was paid for runs out.
+func hasRed(status string) bool {
+	return status == "active" || status == "past_due"
+}
+
+type SubscriptionResponse struct {
+	Plan             entitlements.Plan           `json:"plan"`
+	Status           string                      `json:"status"`
+	CurrentPeriodEnd *time.Time                  `json:"current_period_end"`
+	History          []SubscriptionEventResponse `json:"history"`
+}
+
+type SubscriptionEventResponse struct {
+	CreatedAt time.Time  `json:"created_at"`
+	Event     string     `json:"event"`
+	Status    string     `json:"status"`
+	PeriodEnd *time.Time `json:"period_end"`
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// errSubscriptionEventStale is returned for events older than the one a
+// subscription is already at, such as a failed payment delivered after the
+// renewal that followed it.
+var errSubscriptionEventStale = errors.New("subscription has a newer event")
+
+// setSubscription moves a user's subscription to status, keeps
+// is_chirpy_red in step with it and records the event in their history, all
+// or nothing. eventAt is when the event happened.
+func (cfg *apiConfig) setSubscription(ctx context.Context, userID uuid.UUID, event, status string, periodEnd sql.NullTime, eventAt time.Time) (database.Subscription, error) {
+	sub := database.Subscription{}
+	err := cfg.inTx(ctx, func(q *database.Queries) error {
+		var err error
+		sub, err = q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{UserID: userID, Status: status, CurrentPeriodEnd: periodEnd, EventAt: eventAt})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errSubscriptionEventStale
+		}
+		if err != nil {
+			return err
+		}
+
+		if hasRed(status) {
+			_, err = q.UpgradeUser(ctx, userID)
+		} else {
+			_, err = q.DowngradeUser(ctx, userID)
+		}
+		if err != nil {
+			return err
+		}
+
+		return q.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{UserID: userID, Event: event, Status: status, PeriodEnd: sub.CurrentPeriodEnd})
+	})
+	if err != nil {
+		return database.Subscription{}, err
+	}
+	return sub, nil
+}
+
+func (cfg *apiConfig) scheduleExpireSubscriptions() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		cfg.expireSubscriptions(context.Background())
+	}
+}
+
+// expireSubscriptions takes Chirpy Red away from members whose paid period
+// has run out without a renewal.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) {
+	lapsed, err := cfg.dbq.GetLapsedSubscriptions(ctx)
+	if err != nil {
+		log.Printf("Get lapsed subscriptions: %s", err)
+		return
+	}
+	for _, sub := range lapsed {
+		// The expiry happened when the period ran out, so a renewal Polka
+		// sent before then still wins if it's processed late.
+		_, err = cfg.setSubscription(ctx, sub.UserID, "subscription.expired", "expired", sql.NullTime{}, sub.CurrentPeriodEnd.Time)
+		if errors.Is(err, errSubscriptionEventStale) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Expire subscription for %s: %s", sub.UserID, err)
+			continue
+		}
+		cfg.audit(ctx, auditEvent{Action: "user.chirpy_red", TargetType: "user", TargetID: sub.UserID.String(), Details: map[string]string{"event": "subscription.expired", "status": "expired"}})
+	}
+}
+
+// userSubscription is a user's plan and subscription status, with up to
+// historyLimit of its most recent events.
+func (cfg *apiConfig) userSubscription(ctx context.Context, user database.User, historyLimit int32) (SubscriptionResponse, error) {
+	res := SubscriptionResponse{Plan: userPlan(user), Status: "none", History: []SubscriptionEventResponse{}}
+
+	sub, err := cfg.dbq.GetSubscription(ctx, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return SubscriptionResponse{}, err
+	}
+	if err == nil {
+		res.Status = sub.Status
+		res.CurrentPeriodEnd = nullTimePtr(sub.CurrentPeriodEnd)
+	}
+
+	events, err := cfg.dbq.GetSubscriptionEvents(ctx, database.GetSubscriptionEventsParams{UserID: user.ID, Limit: historyLimit})
+	if err != nil {
+		return SubscriptionResponse{}, err
+	}
+	for _, e := range events {
+		res.History = append(res.History, SubscriptionEventResponse{CreatedAt: e.CreatedAt, Event: e.Event, Status: e.Status, PeriodEnd: nullTimePtr(e.PeriodEnd)})
+	}
+	return res, nil
+}
+
+func (cfg *apiConfig) getSubscription(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	user, err := cfg.dbq.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	res, err := cfg.userSubscription(r.Context(), user, SUBSCRIPTION_HISTORY_LIMIT)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func throttleKeys(email, ip string) []string {
+	return []string{"email:" + strings.ToLower(strings.TrimSpace(email)), "ip:" + ip}
+}
+
+// loginLockedFor reports how long logins for this email or from this IP are
+// still locked out, or zero if neither is.
+func (cfg *apiConfig) loginLockedFor(ctx context.Context, email, ip string) time.Duration {
+	var wait time.Duration
+	for _, key := range throttleKeys(email, ip) {
+		t, err := cfg.dbq.GetLoginThrottle(ctx, key)
+		if err != nil || !t.LockedUntil.Valid {
+			continue
+		}
+		if d := time.Until(t.LockedUntil.Time); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, email string, userID uuid.NullUUID, ip, reason string) {
+	err := cfg.dbq.CreateLoginFailure(ctx, database.CreateLoginFailureParams{Email: email, UserID: userID, Ip: ip, Reason: reason})
+	if err != nil {
+		log.Printf("Record login failure: %s", err)
+	}
+	cfg.audit(ctx, auditEvent{Actor: userID, Action: "login.failed", TargetType: "email", TargetID: email, IP: ip, Details: map[string]string{"reason": reason}})
+	for _, key := range throttleKeys(email, ip) {
+		t, err := cfg.dbq.RecordLoginThrottleFailure(ctx, key)
+		if err != nil {
+			log.Printf("Record login failure: %s", err)
+			continue
+		}
+		d := auth.LockoutDuration(int(t.Failures))
+		if d == 0 {
+			continue
+		}
+		err = cfg.dbq.LockLoginThrottle(ctx, database.LockLoginThrottleParams{Key: key, LockedUntil: sql.NullTime{Time: time.Now().Add(d), Valid: true}})
+		if err != nil {
+			log.Printf("Record login failure: %s", err)
+		}
+	}
+}
+
+// clearLoginFailures resets the account's counter after a successful login.
+// The IP counter is left alone so one valid account can't be used to reset
+// an attacker's budget.
+func (cfg *apiConfig) clearLoginFailures(ctx context.Context, email string) {
+	err := cfg.dbq.ClearLoginThrottle(ctx, throttleKeys(email, "")[0])
+	if err != nil {
+		log.Printf("Clear login failures: %s", err)
+	}
+}
+
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+}
+
+func writeLocked(w http.ResponseWriter, wait time.Duration) {
+	setRetryAfter(w, wait)
+	w.WriteHeader(429)
+	w.Write([]byte("Too many failed login attempts"))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+)
+
+type APITokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func apiTokenResponse(t database.ApiToken) APITokenResponse {
+	res := APITokenResponse{ID: t.ID, CreatedAt: t.CreatedAt, Name: t.Name, Scopes: strings.Fields(t.Scopes)}
+	if t.ExpiresAt.Valid {
+		res.ExpiresAt = &t.ExpiresAt.Time
+	}
+	if t.LastUsedAt.Valid {
+		res.LastUsedAt = &t.LastUsedAt.Time
+	}
+	return res
+}
+
+// authenticate accepts either a signed access token or a personal API token,
+// and refuses both while the user is suspended.
+func (cfg *apiConfig) authenticate(ctx context.Context, token string) (auth.Principal, error) {
+	principal, err := cfg.authenticateToken(ctx, token)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	suspended, err := cfg.isSuspended(ctx, principal.UserID)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	if suspended {
+		return auth.Principal{}, errors.New("user suspended")
+	}
+	return principal, nil
+}
+
+func (cfg *apiConfig) authenticateToken(ctx context.Context, token string) (auth.Principal, error) {
+	if !auth.IsAPIToken(token) {
+		principal, err := auth.ValidateJWT(token, cfg.keys)
+		if err != nil || principal.ClientID == "" {
+			return principal, err
+		}
+		// Revoking an app's authorization must cut off its access tokens too.
+		_, err = cfg.dbq.GetOAuthGrant(ctx, database.GetOAuthGrantParams{UserID: principal.UserID, ClientID: principal.ClientID})
+		if err != nil {
+			return auth.Principal{}, err
+		}
+		return principal, nil
+	}
+
+	t, err := cfg.dbq.GetAPITokenByHash(ctx, auth.HashToken(token))
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	if t.RevokedAt.Valid || (t.ExpiresAt.Valid && t.ExpiresAt.Time.Before(time.Now())) {
+		return auth.Principal{}, errors.New("api token revoked or expired")
+	}
+
+	err = cfg.dbq.TouchAPIToken(ctx, t.ID)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	return auth.Principal{UserID: t.UserID, Scopes: strings.Fields(t.Scopes), APIToken: true, Role: auth.RoleUser}, nil
+}
+
+func (cfg *apiConfig) createAPIToken(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if !principal.FirstParty() {
+		w.WriteHeader(403)
+		return
+	}
+
+	type tokenInput struct {
+		Name             string   `json:"name"`
+		Scopes           []string `json:"scopes"`
+		ExpiresInSeconds int      `json:"expires_in_seconds"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	input := tokenInput{}
+	err := decoder.Decode(&input)
+	if err != nil || len(strings.TrimSpace(input.Name)) == 0 || len(input.Scopes) == 0 || input.ExpiresInSeconds < 0 {
+		w.WriteHeader(400)
+		return
+	}
+
+	for _, s := range input.Scopes {
+		if !slices.Contains(auth.KnownScopes, s) {
+			w.WriteHeader(400)
+			return
+		}
+	}
+	// A token can never carry more access than the credential that made it.
+	if !principal.HasScopes(input.Scopes...) {
+		w.WriteHeader(403)
+		return
+	}
+
+	token, err := auth.MakeAPIToken()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	expires := sql.NullTime{}
+	if input.ExpiresInSeconds > 0 {
+		expires = sql.NullTime{Time: time.Now().Add(time.Duration(input.ExpiresInSeconds) * time.Second), Valid: true}
+	}
+
+	params := database.CreateAPITokenParams{
+		UserID:    principal.UserID,
+		Name:      strings.TrimSpace(input.Name),
+		TokenHash: auth.HashToken(token),
+		Scopes:    strings.Join(input.Scopes, " "),
+		ExpiresAt: expires,
+	}
+	t, err := cfg.dbq.CreateAPIToken(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "api_token.create", TargetType: "api_token", TargetID: t.ID.String(), IP: clientIP(r), Details: map[string]string{"scopes": t.Scopes}})
+
+	res := apiTokenResponse(t)
+	res.Token = token
+	body, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) getAPITokens(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	tokens, err := cfg.dbq.GetAPITokensUser(r.Context(), principal.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []APITokenResponse{}
+	for _, t := range tokens {
+		res = append(res, apiTokenResponse(t))
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(body)
+}
+
+func (cfg *apiConfig) revokeAPIToken(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	tid, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	n, err := cfg.dbq.RevokeAPIToken(r.Context(), database.RevokeAPITokenParams{ID: tid, UserID: principal.UserID})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if n == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "api_token.revoke", TargetType: "api_token", TargetID: tid.String(), IP: clientIP(r)})
+	w.WriteHeader(204)
+}
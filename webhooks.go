@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/haneyeric/chirpy/internal/auth"
+	"github.com/haneyeric/chirpy/internal/database"
+	"github.com/haneyeric/chirpy/internal/entitlements"
+)
+
+const POLKA_WEBHOOK_MAX_BYTES = 64 * 1024
+const WEBHOOK_EVENTS_LIMIT = 50
+
+// An event still pending this long after it was claimed is taken to have
+// been abandoned, and a redelivery of it is processed again.
+const WEBHOOK_EVENT_LEASE = 5 * time.Minute
+
+var errWebhookUnknownUser = errors.New("webhook names an unknown user")
+
+type polkaWebhookInput struct {
+	ID    string `json:"id,omitempty"`
+	Event string `json:"event,omitempty"`
+	Data  struct {
+		UserID    string     `json:"user_id,omitempty"`
+		PeriodEnd *time.Time `json:"period_end,omitempty"`
+	} `json:"data,omitempty"`
+}
+
+// polkaEventID is the ID Polka gave the event, which is what redeliveries
+// are recognized by. Payloads without one are each stored as a new event
+// and processed again. Nothing else identifies a retry reliably: the same
+// body can legitimately be sent twice, and a retry outside the signature
+// tolerance has to be signed with a new timestamp to be accepted at all.
+// Only events with an ID are safe to retry.
+func polkaEventID(input polkaWebhookInput) string {
+	if input.ID != "" {
+		return input.ID
+	}
+	return "unkeyed:" + uuid.NewString()
+}
+
+// polkaAuthentic checks a webhook's signature when signing secrets are
+// configured, and otherwise falls back to the static API key.
+func (cfg *apiConfig) polkaAuthentic(r *http.Request, body []byte) bool {
+	if len(cfg.polkaSecrets) > 0 {
+		err := auth.VerifyWebhook(r.Header.Get("Polka-Timestamp"), r.Header.Get("Polka-Signature"), body, cfg.polkaSecrets, cfg.polkaTolerance, time.Now())
+		if err != nil {
+			log.Printf("Polka webhook from %s: %s", clientIP(r), err)
+			return false
+		}
+		return true
+	}
+
+	apikey, err := auth.GetApiKey(r.Header)
+	if err != nil || cfg.Polka_Key == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(apikey), []byte(cfg.Polka_Key)) == 1
+}
+
+func (cfg *apiConfig) polkaWebhook(w http.ResponseWriter, r *http.Request) {
+	// The signature covers the exact bytes sent, so the body is read whole
+	// before it's decoded.
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, POLKA_WEBHOOK_MAX_BYTES))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	if !cfg.polkaAuthentic(r, body) {
+		w.WriteHeader(401)
+		return
+	}
+
+	// A payload that doesn't decode is still stored, and fails processing
+	// below.
+	input := polkaWebhookInput{}
+	json.Unmarshal(body, &input)
+
+	event, err := cfg.dbq.ClaimWebhookEvent(r.Context(), database.ClaimWebhookEventParams{
+		ID:          polkaEventID(input),
+		Source:      "polka",
+		Event:       input.Event,
+		Payload:     string(body),
+		StaleBefore: time.Now().Add(-WEBHOOK_EVENT_LEASE),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Already processed, or another delivery of it is in progress and
+		// hasn't outlived its lease.
+		w.WriteHeader(204)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	_, err = cfg.runWebhookEvent(r.Context(), event, clientIP(r))
+	if errors.Is(err, errWebhookUnknownUser) {
+		w.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// runWebhookEvent processes a claimed event and records how that went.
+func (cfg *apiConfig) runWebhookEvent(ctx context.Context, event database.WebhookEvent, ip string) (database.WebhookEvent, error) {
+	status, err := cfg.processPolkaEvent(ctx, []byte(event.Payload), event.ReceivedAt, ip)
+	params := database.FinishWebhookEventParams{ID: event.ID, Status: status}
+	if err != nil {
+		log.Printf("Webhook event %s: %s", event.ID, err)
+		params.Status = "failed"
+		params.LastError = err.Error()
+	}
+	finished, ferr := cfg.dbq.FinishWebhookEvent(ctx, params)
+	if ferr != nil {
+		log.Printf("Finish webhook event %s: %s", event.ID, ferr)
+		return event, err
+	}
+	return finished, err
+}
+
+// processPolkaEvent applies a Polka payload first received at receivedAt,
+// returning "processed", or "ignored" for events that don't concern us or
+// that a later event has already overtaken.
+func (cfg *apiConfig) processPolkaEvent(ctx context.Context, payload []byte, receivedAt time.Time, ip string) (string, error) {
+	input := polkaWebhookInput{}
+	err := json.Unmarshal(payload, &input)
+	if err != nil {
+		return "", err
+	}
+
+	status, ok := PolkaEvents[input.Event]
+	if !ok {
+		return "ignored", nil
+	}
+
+	id, err := uuid.Parse(input.Data.UserID)
+	if err != nil {
+		return "", errWebhookUnknownUser
+	}
+	_, err = cfg.dbq.GetUserByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errWebhookUnknownUser
+	}
+	if err != nil {
+		return "", err
+	}
+
+	periodEnd := sql.NullTime{}
+	if input.Data.PeriodEnd != nil {
+		periodEnd = sql.NullTime{Time: *input.Data.PeriodEnd, Valid: true}
+	} else if status == "active" {
+		periodEnd = sql.NullTime{Time: time.Now().Add(SUBSCRIPTION_PERIOD), Valid: true}
+	}
+
+	// Events are ordered by when Polka first delivered them, which a retry
+	// or replay doesn't change.
+	sub, err := cfg.setSubscription(ctx, id, input.Event, status, periodEnd, receivedAt)
+	if errors.Is(err, errSubscriptionEventStale) {
+		return "ignored", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	cfg.audit(ctx, auditEvent{Action: "user.chirpy_red", TargetType: "user", TargetID: id.String(), IP: ip, Details: map[string]string{"event": input.Event, "status": status}})
+	if input.Event == "user.upgraded" {
+		cfg.emitWebhook(ctx, id, "user.upgraded", webhookUserUpgraded{UserID: id, Plan: entitlements.PlanRed, CurrentPeriodEnd: nullTimePtr(sub.CurrentPeriodEnd)})
+	}
+	return "processed", nil
+}
+
+func (cfg *apiConfig) getWebhookEvents(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	params := database.GetWebhookEventsParams{MaxEvents: WEBHOOK_EVENTS_LIMIT}
+	if s := r.URL.Query().Get("status"); s != "" {
+		params.Status = sql.NullString{String: s, Valid: true}
+	}
+
+	events, err := cfg.dbq.GetWebhookEvents(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if events == nil {
+		events = []database.WebhookEvent{}
+	}
+	writeAdminJSON(w, 200, events)
+}
+
+func (cfg *apiConfig) replayWebhookEvent(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	id := r.PathValue("id")
+
+	_, err := cfg.dbq.GetWebhookEvent(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	// Only failed events, or ones stuck part-way through for longer than
+	// the lease, can be replayed; one still being processed can't.
+	event, err := cfg.dbq.RetryWebhookEvent(r.Context(), database.RetryWebhookEventParams{ID: id, StaleBefore: time.Now().Add(-WEBHOOK_EVENT_LEASE)})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(409)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	cfg.audit(r.Context(), auditEvent{Actor: auditActor(principal.UserID), Action: "webhook.replay", TargetType: "webhook_event", TargetID: id, IP: clientIP(r)})
+
+	event, _ = cfg.runWebhookEvent(r.Context(), event, clientIP(r))
+	writeAdminJSON(w, 200, event)
+}